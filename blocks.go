@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"gotdr/sor"
+)
+
+// listBlocks implements the "gotdr blocks" command.
+func listBlocks(arguments []string) {
+	fs := flag.NewFlagSet("blocks", flag.ExitOnError)
+
+	filePath := fs.String("file", "", "Path to the sor file to inspect")
+	dump := fs.String("hexdump", "", "Optional - Name of the block to print as a hex dump")
+	extract := fs.String("extract", "", "Optional - Name of the block to save to a file")
+	out := fs.String("out", "", "Optional - Path of the file receiving the extracted block. Default=<block name>.bin")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotdr blocks [-hexdump name] [-extract name [-out path]] file")
+		fs.PrintDefaults()
+	}
+
+	nukeIfErr(fs.Parse(arguments))
+
+	// the file may be given before the flags.
+	if *filePath == "" && fs.NArg() > 0 {
+		*filePath = fs.Arg(0)
+		nukeIfErr(fs.Parse(fs.Args()[1:]))
+	}
+	if *filePath == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*filePath)
+	nukeIfErr(err)
+	blocks, err := sor.ReadBlocks(f)
+	f.Close()
+	nukeIfErr(err)
+
+	if *dump == "" && *extract == "" {
+		printBlocks(blocks)
+		return
+	}
+
+	if *dump != "" {
+		b := findBlock(blocks, *dump)
+		fmt.Print(hex.Dump(b.Data))
+	}
+
+	if *extract != "" {
+		b := findBlock(blocks, *extract)
+		target := *out
+		if target == "" {
+			target = b.Name + ".bin"
+		}
+		nukeIfErr(os.WriteFile(target, b.Data, 0644))
+		fmt.Println(b.Name, "has been extracted to", target)
+	}
+}
+
+// printBlocks prints the Map directory as a table.
+func printBlocks(blocks []sor.Block) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Name\tRevision\tOffset\tSize\tNote")
+
+	named := len(blocks) > 0 && bytes.HasPrefix(blocks[0].Data, []byte("Map\x00"))
+	for _, b := range blocks {
+		var note string
+		switch {
+		case b.Data == nil:
+			note = "truncated"
+		case named && !bytes.HasPrefix(b.Data, append([]byte(b.Name), 0)):
+			note = "name header not found"
+		}
+		fmt.Fprintf(w, "%s\t%.2f\t%d\t%d\t%s\n", b.Name, float64(b.Revision)/100, b.Offset, b.Size, note)
+	}
+
+	w.Flush()
+}
+
+// findBlock returns the named block, it stops the program when the file does not hold it.
+func findBlock(blocks []sor.Block, name string) sor.Block {
+	for _, b := range blocks {
+		if b.Name != name {
+			continue
+		}
+		if b.Data == nil {
+			nukeIfErr(fmt.Errorf("%s: the block is truncated", name))
+		}
+		return b
+	}
+
+	nukeIfErr(fmt.Errorf("%s: no such block", name))
+	return sor.Block{}
+}
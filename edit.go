@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gotdr/sor"
+)
+
+// editableField is a GenParams or SupParams field which can be changed by the edit command.
+type editableField struct {
+	usage    string
+	supplier bool
+	set      func(t *sor.Trace, v string)
+}
+
+var editableFields = map[string]editableField{
+	"lang":           {"Language code (2 characters)", false, func(t *sor.Trace, v string) { t.GenParams.Lang = v }},
+	"cable":          {"Cable ID", false, func(t *sor.Trace, v string) { t.GenParams.CableID = v }},
+	"fiber":          {"Fiber ID", false, func(t *sor.Trace, v string) { t.GenParams.FiberID = v }},
+	"locA":           {"Originating location", false, func(t *sor.Trace, v string) { t.GenParams.LocationA = v }},
+	"locB":           {"Terminating location", false, func(t *sor.Trace, v string) { t.GenParams.LocationB = v }},
+	"cableCode":      {"Cable code", false, func(t *sor.Trace, v string) { t.GenParams.CableCode = v }},
+	"buildCondition": {"Build condition (2 characters)", false, func(t *sor.Trace, v string) { t.GenParams.BuildCondition = v }},
+	"operator":       {"Operator", false, func(t *sor.Trace, v string) { t.GenParams.Operator = v }},
+	"comment":        {"Comment", false, func(t *sor.Trace, v string) { t.GenParams.Comment = v }},
+	"supplier":       {"OTDR supplier", true, func(t *sor.Trace, v string) { t.Supplier.OTDRSupplier = v }},
+	"otdrName":       {"OTDR name", true, func(t *sor.Trace, v string) { t.Supplier.OTDRName = v }},
+	"otdrSN":         {"OTDR serial number", true, func(t *sor.Trace, v string) { t.Supplier.OTDRsn = v }},
+	"module":         {"OTDR module name", true, func(t *sor.Trace, v string) { t.Supplier.OTDRModuleName = v }},
+	"moduleSN":       {"OTDR module serial number", true, func(t *sor.Trace, v string) { t.Supplier.OTDRModuleSN = v }},
+	"swVersion":      {"OTDR software version", true, func(t *sor.Trace, v string) { t.Supplier.OTDRswVersion = v }},
+	"otherInfo":      {"OTDR other info", true, func(t *sor.Trace, v string) { t.Supplier.OTDROtherInfo = v }},
+}
+
+// editSorFiles implements the "gotdr edit" command.
+func editSorFiles(arguments []string) {
+	fs := flag.NewFlagSet("edit", flag.ExitOnError)
+
+	filePath := fs.String("file", "", "Path to the sor file to edit")
+	out := fs.String("out", "", "Optional - Path of the edited file. Default=the input file is overwritten")
+	mapping := fs.String("csv", "", "Optional - CSV file mapping the file names (first column) to the new values, the header holds the field names")
+	outDir := fs.String("outdir", "", "Optional - Folder receiving the files edited with -csv. Default=the input files are overwritten")
+	force := fs.String("force", "no", "Optional - whether to edit the files whose checksum does not match, yes , no. Default=no")
+
+	values := map[string]*string{}
+	for name, field := range editableFields {
+		values[name] = fs.String(name, "", "Optional - New "+field.usage)
+	}
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotdr edit -file path [-out path] [-force yes] [-cable value] [-fiber value] ...")
+		fmt.Fprintln(fs.Output(), "       gotdr edit -csv mapping.csv [-outdir folder]")
+		fs.PrintDefaults()
+	}
+
+	nukeIfErr(fs.Parse(arguments))
+
+	if *mapping != "" {
+		edits, err := readEditMapping(*mapping)
+		nukeIfErr(err)
+
+		for _, e := range edits {
+			target := e.file
+			if *outDir != "" {
+				target = filepath.Join(*outDir, filepath.Base(e.file))
+			}
+			if err := editSorFile(e.file, target, e.changes, strings.EqualFold(*force, "yes")); err != nil {
+				log.Println(err)
+				continue
+			}
+			fmt.Println(target, "has been updated")
+		}
+		return
+	}
+
+	if *filePath == "" && fs.NArg() > 0 {
+		*filePath = fs.Arg(0)
+	}
+	if *filePath == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	changes := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		if _, ok := editableFields[f.Name]; ok {
+			changes[f.Name] = *values[f.Name]
+		}
+	})
+
+	if len(changes) == 0 {
+		log.Fatalln("no field to edit has been specified")
+	}
+
+	target := *filePath
+	if *out != "" {
+		target = *out
+	}
+
+	nukeIfErr(editSorFile(*filePath, target, changes, strings.EqualFold(*force, "yes")))
+	fmt.Println(target, "has been updated")
+}
+
+// fileEdit is a row of the bulk edit CSV file, the changes to apply to one sor file.
+type fileEdit struct {
+	file    string
+	changes map[string]string
+}
+
+// readEditMapping reads the bulk edit CSV file. The first column holds the sor file paths, relative to the CSV file folder,
+// and the other columns are named after the editable fields. Empty cells leave the field unchanged.
+// The rows are returned in the file order, and a sor file listed twice is an error rather than one row silently winning.
+func readEditMapping(filename string) ([]fileEdit, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	for _, name := range header[1:] {
+		if _, ok := editableFields[name]; !ok {
+			return nil, fmt.Errorf("%s: unknown field %q", filename, name)
+		}
+	}
+
+	var edits []fileEdit
+	lines := map[string]int{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+
+		file := record[0]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(filename), file)
+		}
+		file = filepath.Clean(file)
+
+		line, _ := r.FieldPos(0)
+		if previous, ok := lines[file]; ok {
+			return nil, fmt.Errorf("%s: line %d: %s is already edited on line %d", filename, line, record[0], previous)
+		}
+		lines[file] = line
+
+		changes := map[string]string{}
+		for i, v := range record[1:] {
+			if v != "" {
+				changes[header[i+1]] = v
+			}
+		}
+		edits = append(edits, fileEdit{file, changes})
+	}
+
+	return edits, nil
+}
+
+// editSorFile applies the changes to the sor file and writes the result to target.
+// Only the GenParams block, and the SupParams block when one of its fields changed, are regenerated.
+// The new checksum would hide a corruption of the source file, so a file whose checksum does not match is only edited when forced.
+func editSorFile(source, target string, changes map[string]string, force bool) error {
+	t, err := sor.ParseFile(source)
+	if err != nil {
+		return err
+	}
+
+	switch t.Checksum.Status {
+	case sor.ChecksumMismatch:
+		if !force {
+			return fmt.Errorf("%s: %w, use -force yes to edit it anyway", source, t.CheckIntegrity())
+		}
+		log.Printf("%s: %v, the new checksum covers the file as it is\n", source, t.CheckIntegrity())
+	case sor.ChecksumUnverified, sor.ChecksumAbsent:
+		log.Printf("%s: the checksum can not be verified, the new checksum covers the file as it is\n", source)
+	}
+
+	blocks := []string{"GenParams"}
+	for name, v := range changes {
+		field := editableFields[name]
+		field.set(t, v)
+		if field.supplier && len(blocks) == 1 {
+			blocks = append(blocks, "SupParams")
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := sor.Rewrite(tmp, t, blocks...); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), target)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gotdr/sor"
+)
+
+// readBlock returns the named block of a sor file, name header included.
+func readBlock(t *testing.T, filename, name string) []byte {
+	t.Helper()
+
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := sor.ReadBlocks(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range blocks {
+		if b.Name == name {
+			return b.Data
+		}
+	}
+	t.Fatalf("%s: no %s block", filename, name)
+	return nil
+}
+
+func TestEditKeepsUntouchedFields(t *testing.T) {
+	source := "sorfiles/2.sor"
+	target := filepath.Join(t.TempDir(), "edited.sor")
+
+	if err := editSorFile(source, target, map[string]string{"cable": "NEW"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the cable id, which follows the name header and the language code, is replaced.
+	before := readBlock(t, source, "GenParams")
+	start := len("GenParams\x00") + 2
+	end := start + bytes.IndexByte(before[start:], 0)
+	want := append(append(append([]byte{}, before[:start]...), "NEW"...), before[end:]...)
+
+	if got := readBlock(t, target, "GenParams"); !bytes.Equal(got, want) {
+		t.Errorf("GenParams\n%q\nwant\n%q", got, want)
+	}
+
+	for _, name := range []string{"SupParams", "FxdParams", "DataPts", "KeyEvents"} {
+		if !bytes.Equal(readBlock(t, target, name), readBlock(t, source, name)) {
+			t.Errorf("%s changed", name)
+		}
+	}
+}
+
+func TestEditChecksumMismatch(t *testing.T) {
+	raw, err := os.ReadFile("sorfiles/exfo.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// change the last byte before the Cksum block.
+	raw[len(raw)-len("Cksum\x00")-3]++
+
+	source := filepath.Join(t.TempDir(), "corrupted.sor")
+	if err := os.WriteFile(source, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(t.TempDir(), "edited.sor")
+
+	if err := editSorFile(source, target, map[string]string{"cable": "NEW"}, false); !errors.Is(err, sor.ErrBadChecksum) {
+		t.Fatalf("editSorFile() = %v, want ErrBadChecksum", err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Error("the corrupted file has been edited")
+	}
+
+	if err := editSorFile(source, target, map[string]string{"cable": "NEW"}, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadEditMapping(t *testing.T) {
+	dir := t.TempDir()
+	mapping := filepath.Join(dir, "mapping.csv")
+
+	if err := os.WriteFile(mapping, []byte("file,cable,fiber\nb.sor,B,\na.sor,,2\nc.sor,C,3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	edits, err := readEditMapping(mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fileEdit{
+		{filepath.Join(dir, "b.sor"), map[string]string{"cable": "B"}},
+		{filepath.Join(dir, "a.sor"), map[string]string{"fiber": "2"}},
+		{filepath.Join(dir, "c.sor"), map[string]string{"cable": "C", "fiber": "3"}},
+	}
+	if !reflect.DeepEqual(edits, want) {
+		t.Errorf("readEditMapping() = %v, want %v", edits, want)
+	}
+
+	// the same file under two names.
+	if err := os.WriteFile(mapping, []byte("file,cable\na.sor,A\nb.sor,B\n./a.sor,C\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readEditMapping(mapping); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("readEditMapping() = %v, want a duplicate on line 4", err)
+	}
+}
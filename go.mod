@@ -4,6 +4,9 @@ go 1.22
 
 toolchain go1.22.4
 
-require github.com/go-echarts/go-echarts/v2 v2.4.1
+require (
+	github.com/go-echarts/go-echarts/v2 v2.4.1
+	golang.org/x/image v0.18.0
+)
 
 require github.com/stretchr/testify v1.8.4 // indirect
@@ -1,11 +1,7 @@
 /*
 Author: Naseredin Aramnejad naseredin.aramnejad@gmail.com
-This script is designed to extract all the possible information from the
-given sor file.
-each sor file (Provided by OTDR Equipment) contains multiple data blocks.
-Formulas and blueprint of this script are inspired by the information provided by:
-Sidney Li
-http://morethanfootnotes.blogspot.com/2015/07/
+This script is the command line front-end of the sor package.
+It exports the parsed sor file(s) to JSON/CSV and draws the trace graph.
 */
 
 package main
@@ -13,31 +9,30 @@ package main
 import (
 	"bytes"
 	"encoding/csv"
-	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"html/template"
 	"io"
 	"log"
 	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"runtime"
 	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"text/template"
 	"time"
 
+	"gotdr/sor"
+
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/opts"
 )
 
-const lightSpeed = 299.79181901 // m/µsec
-
 func nukeIfErr(err error) {
 	if err != nil {
 		log.Fatalln(err.Error())
@@ -63,10 +58,6 @@ func openBrowser(url string) {
 	}
 }
 
-func mod(a, b float64) float64 {
-	return a - b*math.Floor(a/b)
-}
-
 func removePaths(stack []byte) []byte {
 	lines := bytes.Split(stack, []byte("\n"))
 	for i, line := range lines {
@@ -95,66 +86,7 @@ func customPanicHandler() {
 	}
 }
 
-// Reverse will reverse the hex string in every 2 bytes. Example: 0ABCD123 => 23D1BC0A.
-func Reverse(s string) string {
-	str := ""
-	for ind := 0; ind < len(s); ind += 2 {
-		str = s[ind:ind+2] + str
-	}
-	return str
-}
-
-func dB(point int64) float64 {
-	return float64(point*-1000) * math.Pow(10, -6)
-}
-
-func ReadSorFile(filename string) otdrRawData {
-
-	r := otdrRawData{
-		Filename: filename,
-	}
-
-	f, err := os.Open(filename)
-	if err != nil {
-		nukeIfErr(err)
-	}
-	defer f.Close()
-
-	// Get the file size
-	stat, err := f.Stat()
-	if err != nil {
-		nukeIfErr(err)
-	}
-
-	// Read the entire file at once
-	buffer := make([]byte, stat.Size())
-	_, err = io.ReadFull(f, buffer)
-	if err != nil {
-		nukeIfErr(err)
-	}
-
-	//Converting the byte array into a hex String
-	r.HexData = hex.EncodeToString(buffer)
-
-	//Converting the HexData to a text string
-	chars, err := hex.DecodeString(r.HexData)
-	nukeIfErr(err)
-
-	r.Decodedfile = string(chars)
-	return r
-}
-
-// parsHexValue calls the Reverse() funcition to reverse the order of the provided HexString and then converts it's value to int64.
-func parsHexValue(hexData string) int64 {
-	output, err := strconv.ParseInt(Reverse(hexData), 16, 64)
-	if err != nil {
-		fmt.Println(err)
-		return 0
-	}
-	return output
-}
-
-func (d *otdrRawData) draw() {
+func (d report) draw() {
 
 	// Create a new line chart instance
 	xValues := make([]opts.LineData, len(d.DataPoints))
@@ -193,8 +125,9 @@ func (d *otdrRawData) draw() {
 		}),
 
 		charts.WithInitializationOpts(opts.Initialization{
-			Width:  "1300px",
-			Height: "500px",
+			ChartID: "otdr",
+			Width:   "1300px",
+			Height:  "500px",
 		}),
 		charts.WithDataZoomOpts(opts.DataZoom{
 			Type:       "inside",
@@ -226,7 +159,7 @@ func (d *otdrRawData) draw() {
 		}
 
 		c := "blue"
-		if strings.Contains(ev.EventType, "EXXX") || strings.Contains(ev.EventType, "E999") {
+		if ev.Code.EndOfFiber {
 			c = "red"
 		}
 
@@ -263,7 +196,7 @@ func (d *otdrRawData) draw() {
 	openBrowser("graph.html")
 }
 
-func (d *otdrRawData) generateHTML(w io.Writer, line *charts.Line) {
+func (d report) generateHTML(w io.Writer, line *charts.Line) {
 	w.Write([]byte(`
     <!DOCTYPE html>
     <html>
@@ -332,6 +265,18 @@ func (d *otdrRawData) generateHTML(w io.Writer, line *charts.Line) {
   tr:nth-child(even) {
     background-color: #f2f2f2;
   }
+
+  .events tbody tr {
+    cursor: pointer;
+  }
+
+  .events tbody tr:hover {
+    background-color: #dde9f8;
+  }
+
+  .events tr.alarm {
+    background-color: #f8d7da;
+  }
   
   /* Responsive design */
   @media (max-width: 768px) {
@@ -354,7 +299,7 @@ func (d *otdrRawData) generateHTML(w io.Writer, line *charts.Line) {
 
 	line.Render(w)
 
-	tmpl := template.Must(template.New("summary").Parse(`
+	tmpl := template.Must(template.New("summary").Funcs(template.FuncMap{"inc": func(i int) int { return i + 1 }}).Parse(`
  			</div>
             <div class="summary">
                 <table>
@@ -373,6 +318,16 @@ func (d *otdrRawData) generateHTML(w io.Writer, line *charts.Line) {
                             <td>Scan Range</td>
                             <td>{{.SR}} m</td>
                         </tr>
+						<tr>
+                            <td>Distance Offsets</td>
+                            <td>acquisition {{.OFF.Acquisition}} m, user {{.OFF.User}} m, {{if .OFF.Corrected}}applied{{else}}raw distances{{end}}</td>
+                        </tr>
+						{{range $i, $s := .SEG}}
+						<tr>
+                            <td>Segment {{inc $i}}</td>
+                            <td>{{$s.PulseWidth}} ns, {{$s.Start}} m - {{$s.End}} m, {{$s.SampleQTY}} samples every {{printf "%.3f" $s.Resolution}} m</td>
+                        </tr>
+						{{end}}
                         <tr>
                             <td>Unit</td>
                             <td>{{.UNIT}}</td>
@@ -404,6 +359,34 @@ func (d *otdrRawData) generateHTML(w io.Writer, line *charts.Line) {
 						<tr>
                             <td>Bellcore Version</td>
                             <td>{{.BLV}}</td>
+                        </tr>
+						<tr>
+                            <td>Trace Type</td>
+                            <td>{{.FP.TraceType}}</td>
+                        </tr>
+						<tr>
+                            <td>Acquisition Range</td>
+                            <td>{{printf "%.3f" .AR}} m</td>
+                        </tr>
+						<tr>
+                            <td>Noise Floor</td>
+                            <td>{{.FP.NoiseFloor}} dB, scale factor {{.FP.NoiseFloorScale}}</td>
+                        </tr>
+						<tr>
+                            <td>Power Offset First Point</td>
+                            <td>{{.FP.PowerOffset}} dB</td>
+                        </tr>
+						<tr>
+                            <td>Loss Threshold</td>
+                            <td>{{.FP.LossThreshold}} dB</td>
+                        </tr>
+						<tr>
+                            <td>Reflectance Threshold</td>
+                            <td>{{.FP.ReflThreshold}} dB</td>
+                        </tr>
+						<tr>
+                            <td>End Of Fiber Threshold</td>
+                            <td>{{.FP.EOFThreshold}} dB</td>
                         </tr>
                     </tbody>
                 </table>
@@ -420,9 +403,113 @@ func (d *otdrRawData) generateHTML(w io.Writer, line *charts.Line) {
 					<tr>
                         <td>Key events qty</td>
                         <td>{{.KE}}</td>
+                    </tr>
+					<tr>
+                        <td>Total Loss</td>
+                        <td>{{.LS.TotalLoss}} dB ({{.LS.SpanStart}} m - {{.LS.SpanEnd}} m)</td>
+                    </tr>
+					<tr>
+                        <td>ORL</td>
+                        <td>{{.LS.ORL}} dB ({{.LS.ORLStart}} m - {{.LS.ORLFinish}} m)</td>
                     </tr>
 					</tbody>
                 </table>
+            </div>
+			<div class="summary">
+                <table class="events">
+                    <thead>
+                        <tr>
+                            <th>No</th>
+                            <th>Type</th>
+                            <th>Distance (m)</th>
+                            <th>Section (m)</th>
+                            <th>Splice Loss (dB)</th>
+                            <th>Reflectance (dB)</th>
+                            <th>Slope (dB/km)</th>
+                            <th>Cumulative Loss (dB)</th>
+                            <th>Comment</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+					{{range .EV}}
+						<tr{{if .Alarm}} class="alarm"{{end}} onclick="zoomTo({{.Index}})">
+                            <td>{{.Number}}</td>
+                            <td title="{{.Type}}">{{.Description}}</td>
+                            <td>{{printf "%.2f" .Distance}}</td>
+                            <td>{{printf "%.2f" .Section}}</td>
+                            <td>{{printf "%.3f" .SpliceLoss}}</td>
+                            <td>{{printf "%.3f" .Reflectance}}</td>
+                            <td>{{printf "%.3f" .Slope}}</td>
+                            <td>{{printf "%.3f" .Cumulative}}</td>
+                            <td>{{.Comment}}</td>
+                        </tr>
+					{{end}}
+                    </tbody>
+                </table>
+            </div>
+			<div class="summary">
+                <table>
+                    <thead>
+                        <tr>
+                            <th>General Parameters</th>
+                            <th>Value</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+						<tr>
+                            <td>Cable Id</td>
+                            <td>{{.GP.CableID}}</td>
+                        </tr>
+						<tr>
+                            <td>Fiber Id</td>
+                            <td>{{.GP.FiberID}}</td>
+                        </tr>
+						<tr>
+                            <td>Fiber Type</td>
+                            <td>{{.GP.FiberType}}</td>
+                        </tr>
+						<tr>
+                            <td>Nominal Wavelength</td>
+                            <td>{{.GP.OTDRWavelength}}</td>
+                        </tr>
+						<tr>
+                            <td>Location A</td>
+                            <td>{{.GP.LocationA}}</td>
+                        </tr>
+						<tr>
+                            <td>Location B</td>
+                            <td>{{.GP.LocationB}}</td>
+                        </tr>
+						<tr>
+                            <td>Cable Code</td>
+                            <td>{{.GP.CableCode}}</td>
+                        </tr>
+						<tr>
+                            <td>Build Condition</td>
+                            <td>{{.GP.BuildCondition}}</td>
+                        </tr>
+						<tr>
+                            <td>User Offset</td>
+                            <td>{{.GP.UserOffset}}</td>
+                        </tr>
+						<tr>
+                            <td>User Offset Distance</td>
+                            <td>{{.GP.UserOffsetDistance}}</td>
+                        </tr>
+						<tr>
+                            <td>Operator</td>
+                            <td>{{.GP.Operator}}</td>
+                        </tr>
+						<tr>
+                            <td>Comment</td>
+                            <td>{{.GP.Comment}}</td>
+                        </tr>
+						<tr>
+                            <td>Language</td>
+                            <td>{{.GP.Lang}}</td>
+                        </tr>
+                    </tbody>
+                </table>
             </div>
 			<div class="summary">
                 <table>
@@ -461,12 +548,20 @@ func (d *otdrRawData) generateHTML(w io.Writer, line *charts.Line) {
                 </table>
             </div>
         </div>
+		<script>
+		// zoomTo centers the chart on the given data point.
+		function zoomTo(index) {
+			var span = Math.max(50, Math.round({{.NP}} * 0.02));
+			goecharts_otdr.dispatchAction({type: "dataZoom", startValue: Math.max(0, index - span), endValue: index + span});
+			window.scrollTo({top: 0, behavior: "smooth"});
+		}
+		</script>
     </body>
     </html>
 `))
 
 	data := struct {
-		DT   time.Time
+		DT   string
 		UNIT string
 		WL   float64
 		PWQ  int64
@@ -482,9 +577,17 @@ func (d *otdrRawData) generateHTML(w io.Writer, line *charts.Line) {
 		OOI  string
 		OMN  string
 		SR   []float64
+		AR   float64
+		FP   sor.FixInfo
 		KE   int
+		GP   sor.GenParam
+		EV   []eventRow
+		SEG  []sor.Segment
+		OFF  sor.Offsets
+		NP   int
+		LS   sor.LinkSummary
 	}{
-		DT:   d.FixedParams.DateTime,
+		DT:   d.dateTime().Format(dateTimeFormat),
 		UNIT: d.FixedParams.Unit,
 		WL:   d.FixedParams.ActualWL,
 		PWQ:  d.FixedParams.PulseWidthNo,
@@ -493,6 +596,8 @@ func (d *otdrRawData) generateHTML(w io.Writer, line *charts.Line) {
 		SQ:   d.FixedParams.SampleQTY,
 		FLEN: d.TotalLength,
 		SR:   d.FixedParams.Range,
+		AR:   d.FixedParams.AR * math.Pow(10, -4) * d.FixedParams.FiberSpeed,
+		FP:   d.FixedParams,
 		BLV:  d.BellCoreVersion,
 		ON:   d.Supplier.OTDRName,
 		OMN:  d.Supplier.OTDRModuleName,
@@ -501,6 +606,12 @@ func (d *otdrRawData) generateHTML(w io.Writer, line *charts.Line) {
 		OMS:  d.Supplier.OTDRModuleSN,
 		OOI:  d.Supplier.OTDROtherInfo,
 		KE:   len(d.Events),
+		GP:   d.GenParams,
+		EV:   d.eventRows(),
+		SEG:  d.Segments,
+		OFF:  d.Offsets,
+		NP:   len(d.DataPoints),
+		LS:   d.Summary,
 	}
 
 	var buf bytes.Buffer
@@ -512,477 +623,104 @@ func (d *otdrRawData) generateHTML(w io.Writer, line *charts.Line) {
 	w.Write([]byte(htmlContent))
 }
 
-func (d *otdrRawData) return_index(loc float64) []float64 {
-	closest := []float64{math.Inf(0), 0}
-
-	for ind, i := range d.DataPoints {
-		if i[0] == loc {
-			return []float64{float64(ind), i[1]}
-		}
-
-		if math.Abs(loc-i[0]) < math.Abs(loc-closest[0]) && i[0] < loc {
-			closest = []float64{float64(ind), i[1]}
-		} else if i[0] > loc {
-			break
-		}
+// selectSegment restricts the data points and the events to the given pulse width segment, numbered from 1.
+func (d report) selectSegment(n int) error {
+	if n < 1 || n > len(d.Segments) {
+		return fmt.Errorf("no segment %d, the trace has %d", n, len(d.Segments))
 	}
 
-	return []float64{closest[0], closest[1]}
-}
+	s := d.Segments[n-1]
+	d.DataPoints = s.DataPoints
+	d.Segments = []sor.Segment{s}
 
-func (d *otdrRawData) mapKeyEvents(events string) map[int][2]int {
-	m := make(map[int][2]int)
-	start := 4
-	evnumbers := int(parsHexValue(events[:start]))
-
-	for i := 1; i <= evnumbers; i++ {
-		if i == evnumbers {
-			m[i] = [2]int{start, len(events) - 46}
-		} else {
-			end := strings.Index(events[start+84:], fmt.Sprintf("%02x00", i+1))
-			if end == -1 {
-				fmt.Println("pattern not found:", events)
-				return nil
-			}
-			end += start + 84
-			m[i] = [2]int{start, end}
-			start = end
+	var events []sor.OTDREvent
+	for _, ev := range d.Events {
+		if ev.EventLocM >= s.Start && ev.EventLocM <= s.End {
+			events = append(events, ev)
 		}
 	}
+	d.Events = events
 
-	return m
+	return nil
 }
 
-func (d *otdrRawData) GetNext(key string) string {
-	if _, exists := d.SecLocs[key]; !exists {
-		log.Println(key, "not found")
-		return ""
-	}
-
-	index := d.SecLocs[key][1]
-	var nextKey string
-	nextIndex := math.Inf(1)
+// eventRows builds the html event table. The section is the fiber before the event and the cumulative loss
+// adds up the section losses and the splice losses of the previous events.
+func (d report) eventRows() []eventRow {
+	rows := make([]eventRow, 0, len(d.Events))
 
-	for k, v := range d.SecLocs {
-		if k == key || len(v) < 2 {
-			continue
+	var previous, cumulative, splice float64
+	for _, ev := range d.Events {
+		section := ev.EventLocM - previous
+		cumulative += splice + ev.Slope*section/1000
+
+		row := eventRow{
+			Number:      ev.EventNumber,
+			Type:        ev.EventType,
+			Description: ev.Code.Description,
+			Distance:    ev.EventLocM,
+			Section:     section,
+			SpliceLoss:  ev.SpliceLoss,
+			Reflectance: ev.RefLoss,
+			Slope:       ev.Slope,
+			Cumulative:  cumulative,
+			Comment:     ev.Comment,
 		}
-		if float64(index) < float64(v[1]) && float64(v[1]) < nextIndex {
-			nextIndex = float64(v[1])
-			nextKey = k
+		if index := d.return_index(ev.EventLocM)[0]; !math.IsInf(index, 0) {
+			row.Index = int(index)
 		}
-	}
-
-	return nextKey
-}
-
-func (d *otdrRawData) GetOrder() {
-	sections := []string{
-		"SupParams",
-		"ExfoNewProprietaryBlock",
-		"Map",
-		"FxdParams",
-		"YokogawaSpecial",
-		"SetupParams",
-		"DataPts",
-		"NokiaParams",
-		"KeyEvents",
-		"GenParams",
-		"WaveMTSParams",
-		"WavetekTwoMTS",
-		"WavetekThreeMTS",
-		"BlocOtdrPrivate",
-		"ActernaConfig",
-		"ActernaMiniCurve",
-		"AcqParam",
-		"ViewParams",
-		"SystemParams",
-		"AnalysisParams",
-		"MiscParams",
-		"JDSUEvenementsMTS",
-		"Cksum",
-	}
-
-	sectionLocations := make(map[string][]int)
-
-	for _, word := range sections {
-		re := regexp.MustCompile(regexp.QuoteMeta(word))
-		matches := re.FindAllStringIndex(d.Decodedfile, -1)
-		locations := make([]int, len(matches))
-		for i, match := range matches {
-			locations[i] = match[0]
-		}
-		sectionLocations[word] = locations
-	}
-
-	if len(sectionLocations["Cksum"]) < 2 {
-		fmt.Printf("%s file has no checksum\n", d.Filename)
-		os.Exit(1)
-	}
-
-	d.SecLocs = sectionLocations
-}
-
-// Under construction
-func (d *otdrRawData) getSetupParams() {
-	// s := SetupParams{}
-
-	if len(d.SecLocs["SetupParams"]) == 0 {
-		// log.Println("SetupParams section is missing")
-		return
-	}
-
-	setupparams := d.HexData[(d.SecLocs["SetupParams"][1]+10)*2 : (d.SecLocs[d.GetNext("SetupParams")][1] * 2)]
-
-	log.Println("setupParams", setupparams)
-}
-
-// Under construction
-func (d *otdrRawData) getMiscParams() {
-	m := MiscParams{}
-
-	if len(d.SecLocs["MiscParams"]) == 0 {
-		return
-	}
-
-	slicedMiscParams := strings.Split(d.Decodedfile[d.SecLocs["MiscParams"][1]+10:d.SecLocs[d.GetNext("MiscParams")][1]], "\x00")[1:]
+		row.Alarm = ev.SpliceLoss > d.thresholds.Loss || (ev.Code.Reflective && ev.RefLoss != 0 && ev.RefLoss > d.thresholds.Reflectance)
+		rows = append(rows, row)
 
-	m.Mode = d.extractData(slicedMiscParams, 0)
-	m.FiberType = d.extractData(slicedMiscParams, 1)
-
-	d.MiscParams = m
-}
-
-// Under construction
-func (d *otdrRawData) getAcqParam() {
-	// a :=AcqParam{}
-
-	if len(d.SecLocs["AcqParam"]) == 0 {
-		// log.Println("AcqParam section is missing")
-		return
-	}
-
-	acqparam := d.HexData[(d.SecLocs["AcqParam"][1]+10)*2 : (d.SecLocs[d.GetNext("AcqParam")][1] * 2)]
-
-	log.Println("AcqParams", acqparam)
-}
-
-// Under construction
-func (d *otdrRawData) getViewParams() {
-	// v :=ViewParams{}
-
-	if len(d.SecLocs["ViewParams"]) == 0 {
-		// log.Println("ViewParams section is missing")
-		return
-	}
-
-	viewparams := d.HexData[(d.SecLocs["ViewParams"][1]+10)*2 : (d.SecLocs[d.GetNext("ViewParams")][1] * 2)]
-
-	log.Println("viewParams", viewparams)
-}
-
-// Under construction
-func (d *otdrRawData) getAnalysisParams() {
-	// a :=AnalysisParams{}
-
-	if len(d.SecLocs["AnalysisParams"]) == 0 {
-		// log.Println("AnalysisParams section is missing")
-		return
-	}
-
-	analyticsparams := d.HexData[(d.SecLocs["AnalysisParams"][1]+10)*2 : (d.SecLocs[d.GetNext("AnalysisParams")][1] * 2)]
-
-	log.Println("AnalyticsParams", analyticsparams)
-}
-
-// Under construction
-func (d *otdrRawData) getSystemParams() {
-	// s :=SystemParams{}
-
-	if len(d.SecLocs["SystemParams"]) == 0 {
-		// log.Println("SystemParams section is missing")
-		return
-	}
-
-	systemparams := d.HexData[(d.SecLocs["SystemParams"][1]+10)*2 : (d.SecLocs[d.GetNext("SystemParams")][1] * 2)]
-
-	log.Println("SystemParams", systemparams)
-}
-
-// getFixedParams function extracts the Fixed Parameters from the sor file and stores it in FixInfos struct.
-func (d *otdrRawData) getFixedParams() {
-
-	f := FixInfo{}
-
-	if len(d.SecLocs["FxdParams"]) == 0 {
-		log.Println("FxdParams section is missing")
-		return
-	}
-
-	fixInfo := d.HexData[(d.SecLocs["FxdParams"][1]+10)*2 : (d.SecLocs[d.GetNext("FxdParams")][1] * 2)]
-	p := 8
-
-	f.DateTime = time.Unix(parsHexValue(fixInfo[:p]), 0)
-
-	unit, err := hex.DecodeString(fixInfo[p : p+4])
-	nukeIfErr(err)
-	p += 4
-
-	f.ActualWL = float64(parsHexValue(fixInfo[p:p+4])) / 10.0
-	p += 4
-
-	f.AO = float64(parsHexValue(fixInfo[p : p+8]))
-	p += 8
-
-	f.AOD = float64(parsHexValue(fixInfo[p : p+8]))
-	p += 8
-
-	f.PulseWidthNo = parsHexValue(fixInfo[p : p+4])
-	p += 4
-
-	for i := 0; i < int(f.PulseWidthNo); i++ {
-		f.PulseWidth = append(f.PulseWidth, parsHexValue(fixInfo[p:p+4]))
-		p += 4
-	}
-
-	resolution_m_p1 := []float64{}
-	for i := 0; i < int(f.PulseWidthNo); i++ {
-		resolution_m_p1 = append(resolution_m_p1, float64(parsHexValue(fixInfo[p:p+8]))*math.Pow(10, -8))
-		p += 8
-	}
-
-	for i := 0; i < int(f.PulseWidthNo); i++ {
-		f.SampleQTY = append(f.SampleQTY, parsHexValue(fixInfo[p:p+8]))
-		p += 8
-	}
-
-	f.IOR = float64(parsHexValue(fixInfo[p : p+8]))
-	p += 8
-
-	f.RefIndex = f.IOR * math.Pow(10, -5)
-
-	f.FiberSpeed = lightSpeed / f.RefIndex
-
-	for i := 0; i < int(f.PulseWidthNo); i++ {
-		f.Resolution = append(f.Resolution, resolution_m_p1[i]*f.FiberSpeed)
-	}
-
-	f.Backscattering = float64(parsHexValue(fixInfo[p:p+4])) * -0.1
-	p += 4
-
-	f.Averaging = parsHexValue(fixInfo[p : p+8])
-	p += 8
-
-	f.AveragingTime = float64(parsHexValue(fixInfo[p:p+4])) / 600
-	p += 4
-
-	for i := 0; i < int(f.PulseWidthNo); i++ {
-		f.Range = append(f.Range, float64(f.SampleQTY[i])*f.Resolution[i])
+		previous = ev.EventLocM
+		splice = ev.SpliceLoss
 	}
 
-	f.Unit = string(unit)
-
-	d.FixedParams = f
+	return rows
 }
 
-func (d *otdrRawData) getDataPoints() {
+func (d report) return_index(loc float64) []float64 {
+	closest := []float64{math.Inf(0), 0}
 
-	if len(d.SecLocs["DataPts"]) == 0 {
-		log.Println("DataPts section is missing")
-		return
-	}
-	dtpoints := d.HexData[d.SecLocs["DataPts"][1]*2 : d.SecLocs[d.GetNext("DataPts")][1]*2][40:]
-	var start int64 = 0
-	var cumulative_length float64 = 0
-
-	for i := range d.FixedParams.SampleQTY {
-
-		qty := int64(d.FixedParams.SampleQTY[i])
-		resolution := d.FixedParams.Resolution[i]
-
-		var j int64
-		for j = 0; j < qty; j++ {
-			index := start + j
-			if index < int64(len(dtpoints)) {
-				hex_value := dtpoints[index*4 : index*4+4]
-				db_value := math.Round(dB(parsHexValue(hex_value))*1000) / 1000
-				passedlen := math.Round(float64(cumulative_length*1000)) / 1000
-				dataPoint := []float64{passedlen, db_value}
-				d.DataPoints = append(d.DataPoints, dataPoint)
-				cumulative_length += resolution
-			}
+	for ind, i := range d.DataPoints {
+		if i[0] == loc {
+			return []float64{float64(ind), i[1]}
 		}
-		start += qty
-	}
-}
-
-// SupParams function extracts the Supplier Parameters from the sor file and stores it in SupParam struct.
-func (d *otdrRawData) getSupParams() {
-
-	if len(d.SecLocs["SupParams"]) == 0 {
-		log.Println("SupParams section is missing")
-		return
-	}
-
-	supString := strings.Split(d.Decodedfile[d.SecLocs["SupParams"][1]+10:d.SecLocs[d.GetNext("SupParams")][1]], "\x00")
-	slicedParams := supString[:len(supString)-1]
-
-	supInfo := SupParam{
-		OTDRSupplier:   strings.TrimSpace(slicedParams[0]),
-		OTDRName:       strings.TrimSpace(slicedParams[1]),
-		OTDRsn:         strings.TrimSpace(slicedParams[2]),
-		OTDRModuleName: strings.TrimSpace(slicedParams[3]),
-		OTDRModuleSN:   strings.TrimSpace(slicedParams[4]),
-		OTDRswVersion:  strings.TrimSpace(slicedParams[5]),
-		OTDROtherInfo:  strings.TrimSpace(slicedParams[6]),
-	}
-
-	d.Supplier = supInfo
-}
-
-func (d *otdrRawData) extractData(data []string, item int) string {
-	if len(data)-1 < item {
-		return ""
-	} else {
-		return strings.TrimSpace(data[item])
-	}
-}
-
-// GenParams function extracts the General Parameters from the sor file and stores it in GenParam struct.
-func (d *otdrRawData) getGenParams() {
-
-	if len(d.SecLocs["GenParams"]) == 0 {
-		log.Println("GenParams section is missing")
-		return
-	}
-
-	genStringBeforeSplit := strings.Split(d.Decodedfile[d.SecLocs["GenParams"][1]+10:d.SecLocs[d.GetNext("GenParams")][1]], "\x00")
-	genString := genStringBeforeSplit[:len(genStringBeforeSplit)-1]
-
-	genInfo := GenParam{
-		CableID:        d.extractData(genString, 0)[2:],
-		Lang:           d.extractData(genString, 0)[:2],
-		FiberID:        d.extractData(genString, 1),
-		LocationA:      d.extractData(genString, 2)[4:],
-		LocationB:      d.extractData(genString, 3),
-		CableCode:      d.extractData(genString, 4),
-		BuildCondition: d.extractData(genString, 5),
-		Operator:       d.extractData(genString, 13),
-		Comment:        d.extractData(genString, 14),
-		FiberType:      "G." + strconv.FormatInt(parsHexValue(hex.EncodeToString([]byte(d.extractData(genString, 2)[:2]))), 10),
-		OTDRWavelength: strconv.FormatInt(parsHexValue(hex.EncodeToString([]byte(d.extractData(genString, 2)[2:4]))), 10) + " nm",
-	}
-
-	d.GenParams = genInfo
-}
 
-// getFiberLength calculates the fiber length and returns it.
-func (d *otdrRawData) getFiberLength() {
-	for _, v := range d.Events {
-		if strings.Contains(v.EventType, "EXX") || strings.Contains(v.EventType, "E99") {
-			d.TotalLength = float64(v.EventLocM)
+		if math.Abs(loc-i[0]) < math.Abs(loc-closest[0]) && i[0] < loc {
+			closest = []float64{float64(ind), i[1]}
+		} else if i[0] > loc {
+			break
 		}
 	}
-}
-
-// getBellCoreVersion reads the bellcore version from the sor file and returns it.
-func (d *otdrRawData) getBellCoreVersion() {
-
-	if len(d.SecLocs["Map"]) == 0 {
-		log.Println("Map section is missing")
-		return
-	}
-	d.BellCoreVersion = float64(parsHexValue(d.HexData[(d.SecLocs["Map"][0]+4)*2:(d.SecLocs["Map"][0]+5)*2])) / 100.0
-}
-
-// getTotalLoss reads the total loss of the fiber from the sor file and returns it.
-func (d *otdrRawData) getTotalLoss() {
-
-	if len(d.SecLocs["WaveMTSParams"]) > 0 {
-		totallossinfo := d.HexData[(d.SecLocs["WaveMTSParams"][1]-22)*2 : (d.SecLocs["WaveMTSParams"][1]-18)*2]
-		d.TotalLoss = float64(parsHexValue(totallossinfo)) * 0.001
-	} else {
-		d.TotalLoss = 0
-	}
-}
-
-// getKeyEvents function extracts the events information from the sor file and stores it in OTDREvent struct.
-func (d *otdrRawData) getKeyEvents() {
-
-	d.Events = map[int]OTDREvent{}
-
-	if len(d.SecLocs["KeyEvents"]) == 0 {
-		log.Println("KeyEvents section is missing")
-		return
-	}
-	events := d.HexData[(d.SecLocs["KeyEvents"][1]+10)*2 : d.SecLocs[d.GetNext("KeyEvents")][1]*2]
-	p := d.mapKeyEvents(events)
-
-	var eventhexlist []string
-	for _, v := range p {
-		eventhexlist = append(eventhexlist, events[v[0]:v[1]])
-	}
-
-	for _, e := range eventhexlist {
-
-		event := OTDREvent{}
-		eNum := int(parsHexValue(e[:4]))
-		event.EventNumber = eNum
-
-		event.EventLocM = float64(parsHexValue(e[4:12])) * (math.Pow(10, -4)) * float64(d.FixedParams.FiberSpeed)
-
-		stValue := mod(event.EventLocM, d.FixedParams.Resolution[0])
-		if stValue >= d.FixedParams.Resolution[0]/2 {
-			event.EventLocM = event.EventLocM + (d.FixedParams.Resolution[0] - stValue)
-		} else {
-			event.EventLocM = event.EventLocM + -stValue
-		}
-
-		event.EventLocM = math.Round(event.EventLocM*1000) / 1000
-
-		event.Slope = float64(parsHexValue(e[12:16])) * 0.001
-		event.SpliceLoss = float64(parsHexValue(e[16:20])) * 0.001
-		if parsHexValue(e[20:28]) > 0 {
-			event.RefLoss = float64((float64(parsHexValue(e[20:28])) - math.Pow(2, 32)) * 0.001)
-		} else {
-			event.RefLoss = float64(parsHexValue(e[20:28]))
-		}
 
-		eventType, err := hex.DecodeString(e[28:44])
-		nukeIfErr(err)
-		event.EventType = string(eventType)
-		event.EndOfPreviousEvent = int(parsHexValue(e[44:52]))
-		event.BegOfCurrentEvent = int(parsHexValue(e[52:60]))
-		event.EndOfCurrentEvent = int(parsHexValue(e[60:68]))
-		event.BegOfNextEvent = int(parsHexValue(e[68:76]))
-		event.PeakCurrentEvent = int(parsHexValue(e[76:84]))
-		if len(e) > 88 {
-			if len(e) < 102 {
-				comment, err := hex.DecodeString(e[84:])
-				nukeIfErr(err)
-				event.Comment = string(comment)
-			} else {
-				comment, err := hex.DecodeString(e[84:102])
-				nukeIfErr(err)
-				event.Comment = string(comment)
-			}
-		}
-		d.Events[eNum] = event
-	}
+	return []float64{closest[0], closest[1]}
 }
 
-func (d *otdrRawData) export2Json() {
+func (d report) export2Json() {
 
 	var exportData = struct {
-		Filename        string            `json:"File Name"`
-		MiscParams      MiscParams        `json:"Misc Params"`
-		FixedParams     FixInfo           `json:"Fixed Parameters"`
-		TotalLoss       float64           `json:"Total Fiber Loss(dB)"`
-		TotalLength     float64           `json:"Fiber Length(km)"`
-		GenParams       GenParam          `json:"General Information"`
-		Supplier        SupParam          `json:"Supplier Information"`
-		Events          map[int]OTDREvent `json:"Key Events"`
-		BellCoreVersion float64           `json:"Bellcore Version"`
+		Filename        string           `json:"File Name"`
+		MiscParams      sor.MiscParams   `json:"Misc Params"`
+		FixedParams     sor.FixInfo      `json:"Fixed Parameters"`
+		TotalLoss       float64          `json:"Total Fiber Loss(dB)"`
+		TotalLength     float64          `json:"Fiber Length(km)"`
+		GenParams       sor.GenParam     `json:"General Information"`
+		Supplier        sor.SupParam     `json:"Supplier Information"`
+		Events          []sor.OTDREvent  `json:"Key Events"`
+		Segments        []sor.Segment    `json:"Segments"`
+		DataPtsInfo     sor.DataPtsInfo  `json:"Data Points Info"`
+		Summary         sor.LinkSummary  `json:"Link Summary"`
+		Offsets         sor.Offsets      `json:"Distance Offsets"`
+		BellCoreVersion float64          `json:"Bellcore Version"`
+		Blocks          []sor.Block      `json:"Blocks"`
+		Checksum        sor.Checksum     `json:"Checksum"`
+		SetupParams     *sor.ParamsBlock `json:"Setup Params,omitempty"`
+		AcqParams       *sor.ParamsBlock `json:"Acquisition Params,omitempty"`
+		ViewParams      *sor.ParamsBlock `json:"View Params,omitempty"`
+		SystemParams    *sor.ParamsBlock `json:"System Params,omitempty"`
+		AnalysisParams  *sor.ParamsBlock `json:"Analysis Params,omitempty"`
+		Vendor          map[string]any   `json:"Vendor,omitempty"`
 	}{
 		Filename:        d.Filename,
 		MiscParams:      d.MiscParams,
@@ -992,7 +730,19 @@ func (d *otdrRawData) export2Json() {
 		GenParams:       d.GenParams,
 		Supplier:        d.Supplier,
 		Events:          d.Events,
+		Segments:        d.Segments,
+		DataPtsInfo:     d.DataPtsInfo,
+		Summary:         d.Summary,
+		Offsets:         d.Offsets,
 		BellCoreVersion: d.BellCoreVersion,
+		Blocks:          d.Blocks,
+		Checksum:        d.Checksum,
+		SetupParams:     d.SetupParams,
+		AcqParams:       d.AcqParams,
+		ViewParams:      d.ViewParams,
+		SystemParams:    d.SystemParams,
+		AnalysisParams:  d.AnalysisParams,
+		Vendor:          d.Vendor,
 	}
 
 	b, err := json.MarshalIndent(exportData, "", "  ")
@@ -1023,6 +773,39 @@ func getCliArgs() map[string]*string {
 	csv := flag.String("csv", "no", "Optional - whether to dump as csv or not, yes , no. Default=no")
 	m["csv"] = csv
 
+	strict := flag.String("strict", "no", "Optional - whether to reject the files with a missing, corrupted or unverifiable checksum, yes , no. Default=no")
+	m["strict"] = strict
+
+	image := flag.String("image", "no", "Optional - whether to render the graph as a static image instead of a browser page, no , svg , png. Default=no")
+	m["image"] = image
+
+	width := flag.String("width", "1300", "Optional - Width of the image in pixels. Default=1300")
+	m["width"] = width
+
+	height := flag.String("height", "500", "Optional - Height of the image in pixels. Default=500")
+	m["height"] = height
+
+	out := flag.String("out", "", "Optional - Path of the image, or the folder receiving the images with -folder. Default=<sor file name>.svg/.png")
+	m["out"] = out
+
+	offsets := flag.String("offsets", "yes", "Optional - whether to correct the distances with the acquisition and user offsets, yes , no (raw distances). Default=yes")
+	m["offsets"] = offsets
+
+	segment := flag.String("segment", "all", "Optional - Pulse width segment (1, 2...) drawn and exported for multi-pulse traces, all. Default=all")
+	m["segment"] = segment
+
+	lossThreshold := flag.String("lossThreshold", "0.5", "Optional - splice loss (dB) above which an event is highlighted in the html report. Default=0.5")
+	m["lossThreshold"] = lossThreshold
+
+	reflThreshold := flag.String("reflThreshold", "-40", "Optional - reflectance (dB) above which an event is highlighted in the html report. Default=-40")
+	m["reflThreshold"] = reflThreshold
+
+	raw := flag.String("raw", "no", "Optional - whether to add the hex dump of the parameters blocks to the json file, yes , no. Default=no")
+	m["raw"] = raw
+
+	tz := flag.String("tz", "UTC", "Optional - IANA time zone (Europe/Paris, America/New_York, Local...) of the dates in the html, csv and image outputs. Default=UTC")
+	m["tz"] = tz
+
 	flag.Parse()
 
 	if len(*m["filePath"]) == 0 {
@@ -1051,12 +834,25 @@ func export2Csv(content csvFiles) {
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	if err := writer.Write([]string{"Filename", "EoF"}); err != nil {
+	if err := writer.Write([]string{"Filename", "EoF", "Checksum", "Events", "Reflective Events", "Non-Reflective Events", "End Of Fiber", "Date"}); err != nil {
 		fmt.Println("Error writing header:", err)
 		return
 	}
+	// the files are parsed concurrently, sort the rows so that the output does not change between runs.
+	sort.Slice(content.Csvs, func(i, j int) bool { return content.Csvs[i].Filename < content.Csvs[j].Filename })
+
 	for _, item := range content.Csvs {
-		if err := writer.Write([]string{filepath.Base(item.Filename), fmt.Sprintf("%.2f", item.EOF)}); err != nil {
+		record := []string{
+			filepath.Base(item.Filename),
+			fmt.Sprintf("%.2f", item.EOF),
+			string(item.Checksum),
+			strconv.Itoa(item.Events),
+			strconv.Itoa(item.Reflective),
+			strconv.Itoa(item.Events - item.Reflective),
+			item.EndOfFiber,
+			item.Date,
+		}
+		if err := writer.Write(record); err != nil {
 			fmt.Println("Error writing record:", err)
 			return
 		}
@@ -1070,6 +866,7 @@ func ParseOTDRFile(args map[string]*string) {
 	var files []string
 	var err error
 	var wg sync.WaitGroup
+	var mu sync.Mutex
 
 	workers, _ := strconv.Atoi(*args["workers"])
 
@@ -1077,6 +874,9 @@ func ParseOTDRFile(args map[string]*string) {
 
 	csvContent := csvFiles{}
 
+	location, err := time.LoadLocation(*args["tz"])
+	nukeIfErr(err)
+
 	if *args["folderPath"] != "" {
 		files, err = getSorFilesPathFromFolder(*args["folderPath"])
 		if strings.EqualFold(*args["json"], "yes") {
@@ -1099,46 +899,93 @@ func ParseOTDRFile(args map[string]*string) {
 		control_buffer <- 1
 
 		go func(control_buffer chan int, wg *sync.WaitGroup) {
-			d := ReadSorFile(f)
-			fmt.Println(1)
-			d.GetOrder()
-			fmt.Println(2)
-			d.getBellCoreVersion()
-			d.getTotalLoss()
-			d.getSupParams()
-			d.getGenParams()
-			d.getFixedParams()
-			d.getDataPoints()
-			d.getKeyEvents()
-			d.getFiberLength()
-			fmt.Println(3)
-
-			d.getSetupParams()
-			d.getMiscParams()
-			d.getViewParams()
-			d.getSystemParams()
-			d.getAnalysisParams()
-			d.getAcqParam()
+			defer func() {
+				wg.Done()
+				<-control_buffer
+			}()
+
+			t, err := sor.ParseFile(f)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			if strings.EqualFold(*args["strict"], "yes") {
+				if err := t.CheckIntegrity(); err != nil {
+					log.Printf("%s: rejected: %v\n", f, err)
+					return
+				}
+				if t.Checksum.Status == sor.ChecksumUnverified {
+					log.Printf("%s: rejected: the %s checksum can not be verified\n", f, t.Supplier.OTDRSupplier)
+					return
+				}
+			}
+			for _, w := range t.Warnings {
+				log.Printf("%s: %v\n", f, w)
+			}
+			if strings.EqualFold(*args["offsets"], "no") {
+				t.SetCorrected(false)
+			}
+			if strings.EqualFold(*args["raw"], "yes") {
+				t.IncludeRaw()
+			}
+			d := report{Trace: t, location: location}
+			d.thresholds.Loss, _ = strconv.ParseFloat(*args["lossThreshold"], 64)
+			d.thresholds.Reflectance, _ = strconv.ParseFloat(*args["reflThreshold"], 64)
+
+			if *args["segment"] != "all" {
+				n, _ := strconv.Atoi(*args["segment"])
+				if err := d.selectSegment(n); err != nil {
+					log.Printf("%s: %v\n", f, err)
+					return
+				}
+			}
 
 			if strings.EqualFold(*args["json"], "yes") {
 
 				d.export2Json()
 			}
 
-			if strings.EqualFold(*args["draw"], "yes") {
+			if format := strings.ToLower(*args["image"]); format != "no" {
+				width, _ := strconv.Atoi(*args["width"])
+				height, _ := strconv.Atoi(*args["height"])
+
+				target := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f)) + "." + format
+				if *args["folderPath"] != "" {
+					target = filepath.Join(*args["out"], target)
+				} else if *args["out"] != "" {
+					target = *args["out"]
+				}
+
+				if err := d.drawImage(format, target, width, height); err != nil {
+					log.Printf("%s: %v\n", f, err)
+				} else {
+					fmt.Println(target, "has been created")
+				}
+			} else if strings.EqualFold(*args["draw"], "yes") {
 
 				d.draw()
 			}
 
 			if strings.EqualFold(*args["csv"], "yes") {
-				csvContent.Csvs = append(csvContent.Csvs, csvFile{
+				c := csvFile{
 					Filename: d.Filename,
 					EOF:      d.TotalLength,
-				})
+					Checksum: d.Checksum.Status,
+					Date:     d.dateTime().Format(dateTimeFormat),
+					Events:   len(d.Events),
+				}
+				for _, ev := range d.Events {
+					if ev.Code.Reflective {
+						c.Reflective++
+					}
+					if ev.Code.EndOfFiber {
+						c.EndOfFiber = ev.Code.Description
+					}
+				}
+				mu.Lock()
+				csvContent.Csvs = append(csvContent.Csvs, c)
+				mu.Unlock()
 			}
-
-			wg.Done()
-			<-control_buffer
 		}(control_buffer, &wg)
 	}
 
@@ -1173,5 +1020,20 @@ func getSorFilesPathFromFolder(p string) ([]string, error) {
 func main() {
 
 	// defer customPanicHandler()
+	if len(os.Args) > 1 && os.Args[1] == "edit" {
+		editSorFiles(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "overlay" {
+		overlayTraces(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "blocks" {
+		listBlocks(os.Args[2:])
+		return
+	}
+
 	ParseOTDRFile(getCliArgs())
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"gotdr/sor"
+)
+
+func TestGenerateHTMLEscapes(t *testing.T) {
+	tr, err := sor.ParseFile("sorfiles/2.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the strings come from the file and must not be able to inject markup in the report.
+	tr.GenParams.Comment = `<script>alert(1)</script>`
+	tr.Events[0].Comment = `"><img src=x onerror=alert(2)>`
+
+	var buf bytes.Buffer
+	report{Trace: tr, location: time.UTC}.generateHTML(&buf, charts.NewLine())
+	html := buf.String()
+
+	for _, s := range []string{tr.GenParams.Comment, tr.Events[0].Comment} {
+		if strings.Contains(html, s) {
+			t.Errorf("%q is not escaped", s)
+		}
+	}
+	if !strings.Contains(html, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Error("the general parameters comment is missing")
+	}
+}
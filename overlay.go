@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotdr/sor"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// overlayColors is the palette of the overlay series, one color per trace.
+var overlayColors = []string{"green", "#4a90e2", "#d0021b", "#f5a623", "#9013fe", "#50e3c2", "#8b572a", "#417505"}
+
+// overlayTraces implements the "gotdr overlay" command.
+func overlayTraces(arguments []string) {
+	fs := flag.NewFlagSet("overlay", flag.ExitOnError)
+
+	out := fs.String("out", "overlay.html", "Optional - Path of the html file. Default=overlay.html")
+	align := fs.String("align", "no", "Optional - whether to shift the traces vertically so that their launch levels match, yes , no. Default=no")
+	tz := fs.String("tz", "UTC", "Optional - IANA time zone of the dates in the legend. Default=UTC")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gotdr overlay [-align yes] [-tz zone] [-out path] a.sor b.sor ...")
+		fs.PrintDefaults()
+	}
+
+	nukeIfErr(fs.Parse(arguments))
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	location, err := time.LoadLocation(*tz)
+	nukeIfErr(err)
+
+	var traces []*sor.Trace
+	for _, f := range fs.Args() {
+		t, err := sor.ParseFile(f)
+		nukeIfErr(err)
+		traces = append(traces, t)
+	}
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithColorsOpts(overlayColors),
+		charts.WithToolboxOpts(opts.Toolbox{
+			Show: opts.Bool(true),
+			Feature: &opts.ToolBoxFeature{
+				DataZoom: &opts.ToolBoxFeatureDataZoom{
+					Show:       opts.Bool(true),
+					YAxisIndex: false,
+				},
+				Restore: &opts.ToolBoxFeatureRestore{
+					Show: opts.Bool(true),
+				},
+				SaveAsImage: &opts.ToolBoxFeatureSaveAsImage{
+					Show: opts.Bool(true),
+				},
+			},
+		}),
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:  "1300px",
+			Height: "600px",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Type: "value",
+			Name: "m",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Type:  "value",
+			Name:  "dB",
+			Scale: opts.Bool(true),
+		}),
+		charts.WithDataZoomOpts(opts.DataZoom{
+			Type:       "inside",
+			XAxisIndex: []int{0},
+		}),
+		charts.WithDataZoomOpts(opts.DataZoom{
+			Type:       "slider",
+			XAxisIndex: []int{0},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+		charts.WithTitleOpts(opts.Title{
+			Title: "GOTDR Overlay",
+		}),
+	)
+
+	for i, t := range traces {
+		color := overlayColors[i%len(overlayColors)]
+
+		// aligning moves every trace to the launch level of the first one.
+		var offset float64
+		if strings.EqualFold(*align, "yes") && len(t.DataPoints) > 0 && len(traces[0].DataPoints) > 0 {
+			offset = launchLevel(traces[0]) - launchLevel(t)
+		}
+
+		points := make([]opts.LineData, len(t.DataPoints))
+		for j, p := range t.DataPoints {
+			points[j] = opts.LineData{Value: []interface{}{p[0], p[1] + offset}}
+		}
+
+		markPoints := make([]opts.MarkPointNameCoordItem, 0, len(t.Events))
+		for _, ev := range t.Events {
+			markPoints = append(markPoints, opts.MarkPointNameCoordItem{
+				Name:       ev.EventType,
+				Value:      strconv.Itoa(ev.EventNumber),
+				Coordinate: []interface{}{ev.EventLocM, levelAt(t, ev.EventLocM) + offset},
+				Symbol:     "pin",
+				ItemStyle: &opts.ItemStyle{
+					Color:   color,
+					Opacity: 0.6,
+				},
+				SymbolSize: 30,
+			})
+		}
+
+		line.AddSeries(seriesName(t, location), points,
+			charts.WithLineChartOpts(opts.LineChart{ShowSymbol: opts.Bool(false)}),
+			charts.WithLineStyleOpts(opts.LineStyle{Color: color, Width: 1}),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: color}),
+			charts.WithMarkPointNameCoordItemOpts(markPoints...),
+			charts.WithMarkPointStyleOpts(opts.MarkPointStyle{Label: &opts.Label{Show: opts.Bool(true)}}),
+		)
+	}
+
+	f, err := os.Create(*out)
+	nukeIfErr(err)
+	nukeIfErr(line.Render(f))
+	nukeIfErr(f.Close())
+
+	fmt.Println(*out, "has been created")
+	openBrowser(*out)
+}
+
+// seriesName names a trace in the legend after its file, wavelength and acquisition date in the given time zone.
+func seriesName(t *sor.Trace, location *time.Location) string {
+	return fmt.Sprintf("%s %.0f nm %s", filepath.Base(t.Filename), t.FixedParams.ActualWL, t.FixedParams.DateTime.In(location).Format("2006-01-02"))
+}
+
+// launchLevel returns the backscatter level at the start of the fiber. The first samples hold the front connector
+// reflection, often saturated, so the level is taken past the dead zone of the first event, or of the front panel when
+// the trace has no event, which lasts about two pulse lengths.
+func launchLevel(t *sor.Trace) float64 {
+	var deadZone float64
+	if len(t.FixedParams.PulseWidth) > 0 {
+		// the pulse width is in ns and the fiber speed in m/µs.
+		deadZone = 2 * float64(t.FixedParams.PulseWidth[0]) * t.FixedParams.FiberSpeed / 1000
+	}
+
+	distance := t.DataPoints[0][0] + deadZone
+	if len(t.Events) > 0 {
+		distance = t.Events[0].EventLocM + deadZone
+	}
+	// stay on the fiber before the next event.
+	if len(t.Events) > 1 && distance >= t.Events[1].EventLocM {
+		distance = (t.Events[0].EventLocM + t.Events[1].EventLocM) / 2
+	}
+	return levelAt(t, distance)
+}
+
+// levelAt returns the trace level at the data point closest to the given distance.
+func levelAt(t *sor.Trace, distance float64) float64 {
+	points := t.DataPoints
+	if len(points) == 0 {
+		return 0
+	}
+	i := sort.Search(len(points), func(i int) bool { return points[i][0] >= distance })
+	if i == len(points) || i > 0 && distance-points[i-1][0] < points[i][0]-distance {
+		i--
+	}
+	return points[i][1]
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"gotdr/sor"
+)
+
+func TestLaunchLevel(t *testing.T) {
+	tr, err := sor.ParseFile("sorfiles/3.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first event of 3.sor is a saturated reflection 30 m from the front panel, the 100 ns pulse spans 20 m.
+	if !tr.Events[0].Code.Saturated {
+		t.Fatalf("the first event of 3.sor is %s", tr.Events[0].EventType)
+	}
+	level := launchLevel(tr)
+	if want := levelAt(tr, tr.Events[0].EventLocM+40.927); level != want {
+		t.Errorf("launchLevel() = %v, want %v", level, want)
+	}
+	if level > -40 {
+		t.Errorf("launch level %v dB taken on the reflection", level)
+	}
+
+	// a saturated front connector does not move the launch level.
+	for _, p := range tr.DataPoints[:20] {
+		p[1] = 0
+	}
+	if got := launchLevel(tr); got != level {
+		t.Errorf("launchLevel() = %v with a saturated front connector, want %v", got, level)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// canvas is the drawing surface of the static renderer, implemented for SVG and PNG.
+type canvas interface {
+	line(x1, y1, x2, y2 float64, c color.RGBA)
+	polyline(points [][2]float64, c color.RGBA)
+	text(x, y float64, s string, c color.RGBA, anchor string)
+	save(w io.Writer) error
+}
+
+var (
+	colorBackground = color.RGBA{255, 255, 255, 255}
+	colorAxis       = color.RGBA{60, 60, 60, 255}
+	colorGrid       = color.RGBA{224, 224, 224, 255}
+	colorTrace      = color.RGBA{0, 128, 0, 255}
+	colorEvent      = color.RGBA{0, 0, 255, 255}
+	colorEndEvent   = color.RGBA{255, 0, 0, 255}
+)
+
+// svgCanvas writes the drawing as SVG elements.
+type svgCanvas struct {
+	width, height int
+	b             strings.Builder
+}
+
+func newSVGCanvas(width, height int) *svgCanvas {
+	c := &svgCanvas{width: width, height: height}
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n", width, height, width, height)
+	fmt.Fprintf(&c.b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", svgColor(colorBackground))
+	return c
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (c *svgCanvas) line(x1, y1, x2, y2 float64, col color.RGBA) {
+	fmt.Fprintf(&c.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", x1, y1, x2, y2, svgColor(col))
+}
+
+func (c *svgCanvas) polyline(points [][2]float64, col color.RGBA) {
+	c.b.WriteString(`<polyline fill="none" stroke-width="1" stroke="` + svgColor(col) + `" points="`)
+	for _, p := range points {
+		fmt.Fprintf(&c.b, "%.1f,%.1f ", p[0], p[1])
+	}
+	c.b.WriteString("\"/>\n")
+}
+
+func (c *svgCanvas) text(x, y float64, s string, col color.RGBA, anchor string) {
+	fmt.Fprintf(&c.b, `<text x="%.1f" y="%.1f" fill="%s" text-anchor="%s">%s</text>`+"\n", x, y, svgColor(col), anchor, html.EscapeString(s))
+}
+
+func (c *svgCanvas) save(w io.Writer) error {
+	_, err := io.WriteString(w, c.b.String()+"</svg>\n")
+	return err
+}
+
+// pngCanvas draws 1 pixel lines and the 7x13 basic font on an RGBA image.
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func newPNGCanvas(width, height int) *pngCanvas {
+	c := &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+	for i := 0; i < len(c.img.Pix); i += 4 {
+		copy(c.img.Pix[i:], []uint8{colorBackground.R, colorBackground.G, colorBackground.B, colorBackground.A})
+	}
+	return c
+}
+
+// line draws with Bresenham's algorithm.
+func (c *pngCanvas) line(x1, y1, x2, y2 float64, col color.RGBA) {
+	x0, y0, x, y := int(math.Round(x1)), int(math.Round(y1)), int(math.Round(x2)), int(math.Round(y2))
+	dx, dy := abs(x-x0), -abs(y-y0)
+	sx, sy := 1, 1
+	if x0 > x {
+		sx = -1
+	}
+	if y0 > y {
+		sy = -1
+	}
+
+	e := dx + dy
+	for {
+		c.img.SetRGBA(x0, y0, col)
+		if x0 == x && y0 == y {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			x0 += sx
+		} else {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func (c *pngCanvas) polyline(points [][2]float64, col color.RGBA) {
+	for i := 1; i < len(points); i++ {
+		c.line(points[i-1][0], points[i-1][1], points[i][0], points[i][1], col)
+	}
+}
+
+func (c *pngCanvas) text(x, y float64, s string, col color.RGBA, anchor string) {
+	d := font.Drawer{Dst: c.img, Src: image.NewUniform(col), Face: basicfont.Face7x13}
+
+	width := float64(d.MeasureString(s).Round())
+	switch anchor {
+	case "middle":
+		x -= width / 2
+	case "end":
+		x -= width
+	}
+
+	d.Dot = fixed.P(int(math.Round(x)), int(math.Round(y)))
+	d.DrawString(s)
+}
+
+func (c *pngCanvas) save(w io.Writer) error {
+	return png.Encode(w, c.img)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// niceStep returns a 1, 2 or 5 times power of ten step splitting span in about n intervals.
+func niceStep(span float64, n int) float64 {
+	if span <= 0 {
+		return 1
+	}
+	raw := span / float64(n)
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+
+	for _, m := range []float64{1, 2, 5} {
+		if raw <= m*magnitude {
+			return m * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// render draws the trace with its axes, grid, event markers and a summary caption.
+func (d report) render(c canvas, width, height int) {
+	const (
+		left   = 60.0
+		right  = 20.0
+		top    = 20.0
+		bottom = 60.0
+	)
+	plotW, plotH := float64(width)-left-right, float64(height)-top-bottom
+
+	minX, maxX := 0.0, 1.0
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, p := range d.DataPoints {
+		maxX = math.Max(maxX, p[0])
+		minY = math.Min(minY, p[1])
+		maxY = math.Max(maxY, p[1])
+	}
+	if len(d.DataPoints) == 0 {
+		minY, maxY = -1, 0
+	}
+
+	stepX := niceStep(maxX-minX, 10)
+	stepY := niceStep(maxY-minY, 8)
+	maxX = math.Ceil(maxX/stepX) * stepX
+	minY = math.Floor(minY/stepY) * stepY
+	maxY = math.Ceil(maxY/stepY) * stepY
+	if maxY == minY {
+		maxY = minY + stepY
+	}
+
+	px := func(x float64) float64 { return left + (x-minX)/(maxX-minX)*plotW }
+	py := func(y float64) float64 { return top + (maxY-y)/(maxY-minY)*plotH }
+
+	for x := minX; x <= maxX+stepX/2; x += stepX {
+		c.line(px(x), top, px(x), top+plotH, colorGrid)
+		c.text(px(x), top+plotH+15, fmt.Sprintf("%g", math.Round(x*1000)/1000), colorAxis, "middle")
+	}
+	for y := minY; y <= maxY+stepY/2; y += stepY {
+		c.line(left, py(y), left+plotW, py(y), colorGrid)
+		c.text(left-5, py(y)+4, fmt.Sprintf("%g", math.Round(y*1000)/1000), colorAxis, "end")
+	}
+	c.line(left, top, left, top+plotH, colorAxis)
+	c.line(left, top+plotH, left+plotW, top+plotH, colorAxis)
+	c.text(left+plotW, top+plotH+30, "Distance (m)", colorAxis, "end")
+	c.text(left, top-6, "dB", colorAxis, "middle")
+
+	// keep the lowest and highest sample of every pixel column, so the reflection peaks survive the decimation.
+	var points [][2]float64
+	column, low, high := -1, 0.0, 0.0
+	flush := func() {
+		if column >= 0 {
+			points = append(points, [2]float64{float64(column), py(high)}, [2]float64{float64(column), py(low)})
+		}
+	}
+	for _, p := range d.DataPoints {
+		x := int(math.Round(px(p[0])))
+		if x != column {
+			flush()
+			column, low, high = x, p[1], p[1]
+			continue
+		}
+		low = math.Min(low, p[1])
+		high = math.Max(high, p[1])
+	}
+	flush()
+	c.polyline(points, colorTrace)
+
+	for _, ev := range d.Events {
+		col := colorEvent
+		if ev.Code.EndOfFiber {
+			col = colorEndEvent
+		}
+		x := px(ev.EventLocM)
+		y := py(d.return_index(ev.EventLocM)[1])
+		c.line(x, y-4, x, y-22, col)
+		c.line(x-4, y-8, x, y-4, col)
+		c.line(x+4, y-8, x, y-4, col)
+		c.text(x, y-25, fmt.Sprint(ev.EventNumber), col, "middle")
+	}
+
+	caption := fmt.Sprintf("%s  |  %.0f nm  |  %s  |  length %.2f m  |  total loss %.3f dB  |  %d events",
+		filepath.Base(d.Filename), d.FixedParams.ActualWL, d.dateTime().Format("2006-01-02 15:04 MST"), d.TotalLength, d.TotalLoss, len(d.Events))
+	c.text(left, float64(height)-12, caption, colorAxis, "start")
+}
+
+// drawImage renders the trace to an SVG or PNG file, depending on format.
+func (d report) drawImage(format, filename string, width, height int) error {
+	var c canvas
+	switch format {
+	case "svg":
+		c = newSVGCanvas(width, height)
+	case "png":
+		c = newPNGCanvas(width, height)
+	default:
+		return fmt.Errorf("unknown image format %q, use svg or png", format)
+	}
+
+	d.render(c, width, height)
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := c.save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
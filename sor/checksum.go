@@ -0,0 +1,132 @@
+package sor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// ChecksumStatus tells whether the value stored in the Cksum block matches the file content.
+// It is unverified for the instruments known to store a value which is not the SR-4731 CRC.
+type ChecksumStatus string
+
+const (
+	ChecksumValid      ChecksumStatus = "valid"
+	ChecksumMismatch   ChecksumStatus = "mismatch"
+	ChecksumAbsent     ChecksumStatus = "absent"
+	ChecksumUnverified ChecksumStatus = "unverified"
+)
+
+// The traces of the JDSU/Viavi MTS platforms store a Cksum value that none of the CRC-16 variants reproduces, over the
+// whole file or any range of blocks, so their checksum can not be verified. The exemption only applies to the files
+// whose SupParams supplier is one of unverifiableSuppliers and which carry one of the MTS blocks.
+var (
+	unverifiableSuppliers = []string{"jdsu", "viavi"}
+	unverifiableBlocks    = []string{"WaveMTSParams", "JDSUEvenementsMTS"}
+)
+
+// Checksum is the integrity information of the sor file.
+type Checksum struct {
+	Status   ChecksumStatus `json:"Status"`
+	Stored   uint16         `json:"Stored"`
+	Computed uint16         `json:"Computed"`
+}
+
+var crc16Table = func() [256]uint16 {
+	var t [256]uint16
+	for i := range t {
+		c := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if c&0x8000 != 0 {
+				c = c<<1 ^ 0x1021
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}()
+
+// crc16 computes the CRC-16/CCITT (polynomial 0x1021, initial value 0xFFFF) used by SR-4731.
+func crc16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
+
+// getChecksum compares the CRC stored in the Cksum block with the one computed over all the preceding bytes.
+func (d *Trace) getChecksum() error {
+	b, ok := d.Block("Cksum")
+	if !ok {
+		d.Checksum = Checksum{Status: ChecksumAbsent}
+		d.Warnings = append(d.Warnings, missing("Cksum"))
+		return nil
+	}
+
+	body, err := d.blockBody("Cksum")
+	if err != nil {
+		return err
+	}
+	if len(body) < 2 {
+		return truncated("Cksum")
+	}
+
+	// the CRC covers the block name header of version 2 files, version 1 blocks have none.
+	end := b.Offset
+	if d.version() >= 2 {
+		end += len(b.Name) + 1
+	}
+
+	d.Checksum = Checksum{
+		Status:   ChecksumValid,
+		Stored:   binary.LittleEndian.Uint16(body),
+		Computed: crc16(d.raw[:end]),
+	}
+
+	if d.Checksum.Stored == d.Checksum.Computed {
+		return nil
+	}
+
+	if d.unverifiableChecksum() {
+		d.Checksum.Status = ChecksumUnverified
+		d.Warnings = append(d.Warnings, &BlockError{Block: "Cksum", Err: fmt.Errorf("stored 0x%04X is not a SR-4731 CRC for %s traces, the file can not be verified", d.Checksum.Stored, d.Supplier.OTDRSupplier)})
+		return nil
+	}
+
+	d.Checksum.Status = ChecksumMismatch
+	d.Warnings = append(d.Warnings, d.CheckIntegrity())
+
+	return nil
+}
+
+// unverifiableChecksum reports whether the file was written by an instrument whose checksum can not be verified.
+func (d *Trace) unverifiableChecksum() bool {
+	supplier := strings.ToLower(d.Supplier.OTDRSupplier)
+	for _, s := range unverifiableSuppliers {
+		if !strings.Contains(supplier, s) {
+			continue
+		}
+		for _, name := range unverifiableBlocks {
+			if d.hasBlock(name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckIntegrity returns an error wrapping ErrBadChecksum or ErrMissingBlock unless the file checksum is valid,
+// or can not be verified because of the instrument which wrote it.
+func (d *Trace) CheckIntegrity() error {
+	switch d.Checksum.Status {
+	case ChecksumValid, ChecksumUnverified:
+		return nil
+	case ChecksumMismatch:
+		return &BlockError{Block: "Cksum", Err: fmt.Errorf("%w: stored 0x%04X, computed 0x%04X", ErrBadChecksum, d.Checksum.Stored, d.Checksum.Computed)}
+	default:
+		return missing("Cksum")
+	}
+}
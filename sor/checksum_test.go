@@ -0,0 +1,106 @@
+package sor
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestCRC16(t *testing.T) {
+	// check value of CRC-16/CCITT-FALSE.
+	if got := crc16([]byte("123456789")); got != 0x29B1 {
+		t.Errorf("crc16 = 0x%04X, want 0x29B1", got)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		file   string
+		status ChecksumStatus
+	}{
+		{"../sorfiles/exfo.sor", ChecksumValid},
+		// the JDSU/Viavi MTS traces do not store the SR-4731 CRC.
+		{"../sorfiles/2.sor", ChecksumUnverified},
+		{"../sorfiles/3.sor", ChecksumUnverified},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseFile(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Checksum.Status != tt.status {
+			t.Errorf("%s: checksum %s, want %s", tt.file, d.Checksum.Status, tt.status)
+		}
+		if err := d.CheckIntegrity(); err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+		}
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	raw, err := os.ReadFile("../sorfiles/exfo.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := ReadBlocks(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// change a sample of the trace.
+	for _, b := range blocks {
+		if b.Name == "DataPts" {
+			raw[b.Offset+b.Size/2]++
+		}
+	}
+
+	d, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Checksum.Status != ChecksumMismatch {
+		t.Errorf("checksum %s, want %s", d.Checksum.Status, ChecksumMismatch)
+	}
+	if err := d.CheckIntegrity(); !errors.Is(err, ErrBadChecksum) {
+		t.Errorf("CheckIntegrity() = %v, want ErrBadChecksum", err)
+	}
+}
+
+func TestChecksumUnverifiedSupplier(t *testing.T) {
+	in, err := ParseFile("../sorfiles/2.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a JDSU block in a trace of another supplier does not exempt it from the CRC check.
+	in.Supplier.OTDRSupplier = "ACME"
+	var buf bytes.Buffer
+	if err := Write(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+
+	blocks, err := ReadBlocks(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range blocks {
+		if b.Name == "DataPts" {
+			raw[b.Offset+b.Size/2]++
+		}
+	}
+
+	d, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.hasBlock("WaveMTSParams") {
+		t.Fatal("the WaveMTSParams block has been dropped")
+	}
+	if d.Checksum.Status != ChecksumMismatch {
+		t.Errorf("checksum %s, want %s", d.Checksum.Status, ChecksumMismatch)
+	}
+}
@@ -0,0 +1,38 @@
+package sor
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors reported by the parser. They are wrapped in a *BlockError naming the offending block,
+// so callers should test for them with errors.Is.
+var (
+	ErrMissingBlock    = errors.New("missing block")
+	ErrTruncatedBlock  = errors.New("truncated block")
+	ErrMalformedBlock  = errors.New("malformed block")
+	ErrBadChecksum     = errors.New("bad checksum")
+	ErrImplausibleDate = errors.New("implausible date")
+)
+
+// BlockError records a parsing failure and the block it happened in.
+type BlockError struct {
+	Block string
+	Err   error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("sor: %s: %v", e.Block, e.Err)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
+
+func missing(block string) error {
+	return &BlockError{Block: block, Err: ErrMissingBlock}
+}
+
+func truncated(block string) error {
+	return &BlockError{Block: block, Err: ErrTruncatedBlock}
+}
@@ -0,0 +1,122 @@
+package sor
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EventCode is the decoded form of the 8 character event type, such as "1F9999LS".
+// The first character tells whether the event is reflective, the second how it was found, the next four hold the
+// landmark number (9999 for none) and the last two the loss measurement technique.
+type EventCode struct {
+	Reflective  bool   `json:"Reflective"`
+	Saturated   bool   `json:"Saturated"`
+	AddedByUser bool   `json:"Added By User"`
+	MovedByUser bool   `json:"Moved By User"`
+	EndOfFiber  bool   `json:"End Of Fiber"`
+	OutOfRange  bool   `json:"Out Of Range"`
+	Landmark    int    `json:"Landmark,omitempty"`
+	Marker      string `json:"Marker,omitempty"`
+	Mode        string `json:"Analysis Mode"`
+	Description string `json:"Description"`
+}
+
+// techniques lists the loss measurement techniques of SR-4731.
+var techniques = map[string]string{
+	"LS": "least squares",
+	"2P": "two point",
+	"BC": "baseline corrected",
+}
+
+// ParseEventCode decodes an event type code. Unknown characters are ignored, so a short or vendor specific code still
+// yields the fields it does carry.
+func ParseEventCode(code string) EventCode {
+	c := EventCode{}
+	code = strings.TrimRight(code, "\x00 ")
+
+	if len(code) > 0 {
+		switch code[0] {
+		case '1':
+			c.Reflective = true
+		case '2':
+			c.Reflective = true
+			c.Saturated = true
+		}
+	}
+
+	if len(code) > 1 {
+		switch code[1] {
+		case 'A':
+			c.AddedByUser = true
+		case 'M':
+			c.MovedByUser = true
+		case 'E':
+			c.EndOfFiber = true
+		case 'D':
+			c.EndOfFiber = true
+			c.MovedByUser = true
+		case 'O':
+			c.OutOfRange = true
+		}
+	}
+
+	// some instruments flag the ends of the launch and receive fibers in the landmark field instead of a number.
+	if len(code) >= 6 {
+		landmark := code[2:6]
+		if n, err := strconv.Atoi(landmark); err == nil {
+			if n != 9999 {
+				c.Landmark = n
+			}
+		} else if landmark[0] == 'L' {
+			c.Marker = "launch fiber end"
+		} else if landmark[0] == 'R' {
+			c.Marker = "receive fiber start"
+		}
+	}
+
+	if len(code) >= 8 {
+		c.Mode = code[6:8]
+	}
+
+	c.Description = c.describe()
+	return c
+}
+
+// describe returns a human readable summary of the code, like "reflective event, end of fiber, least squares".
+func (c EventCode) describe() string {
+	kind := "non-reflective event"
+	switch {
+	case c.Saturated:
+		kind = "saturated reflective event"
+	case c.Reflective:
+		kind = "reflective event"
+	}
+	parts := []string{kind}
+
+	switch {
+	case c.EndOfFiber && c.MovedByUser:
+		parts = append(parts, "end of fiber moved by user")
+	case c.EndOfFiber:
+		parts = append(parts, "end of fiber")
+	case c.AddedByUser:
+		parts = append(parts, "added by user")
+	case c.MovedByUser:
+		parts = append(parts, "moved by user")
+	case c.OutOfRange:
+		parts = append(parts, "beyond the end of fiber")
+	}
+
+	if c.Marker != "" {
+		parts = append(parts, c.Marker)
+	}
+	if c.Landmark != 0 {
+		parts = append(parts, "landmark "+strconv.Itoa(c.Landmark))
+	}
+	if t, ok := techniques[c.Mode]; ok {
+		parts = append(parts, t)
+	} else if strings.TrimSpace(c.Mode) != "" {
+		parts = append(parts, "technique "+c.Mode)
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,36 @@
+package sor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEventCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want EventCode
+	}{
+		{"1F9999LS", EventCode{Reflective: true, Mode: "LS", Description: "reflective event, least squares"}},
+		{"0F00122P", EventCode{Landmark: 12, Mode: "2P", Description: "non-reflective event, landmark 12, two point"}},
+		{"2E9999BC", EventCode{Reflective: true, Saturated: true, EndOfFiber: true, Mode: "BC",
+			Description: "saturated reflective event, end of fiber, baseline corrected"}},
+		{"0D9999LS", EventCode{EndOfFiber: true, MovedByUser: true, Mode: "LS",
+			Description: "non-reflective event, end of fiber moved by user, least squares"}},
+		{"0O9999XY", EventCode{OutOfRange: true, Mode: "XY", Description: "non-reflective event, beyond the end of fiber, technique XY"}},
+		// the landmark field holds a launch or receive fiber marker instead of a number.
+		{"1FL999LS", EventCode{Reflective: true, Marker: "launch fiber end", Mode: "LS",
+			Description: "reflective event, launch fiber end, least squares"}},
+		{"0AR0012P", EventCode{AddedByUser: true, Marker: "receive fiber start", Mode: "2P",
+			Description: "non-reflective event, added by user, receive fiber start, two point"}},
+		// short and padded codes keep the fields they carry.
+		{"1M", EventCode{Reflective: true, MovedByUser: true, Description: "reflective event, moved by user"}},
+		{"0F9999  \x00", EventCode{Description: "non-reflective event"}},
+		{"", EventCode{Description: "non-reflective event"}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseEventCode(tt.code); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseEventCode(%q) = %+v, want %+v", tt.code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package sor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"math"
+	"strings"
+)
+
+// The JDSU/Viavi (formerly Wavetek and Acterna) blocks are not documented, their layout was worked out from the
+// traces saved by the MTS/T-BERD platforms, so only the fields which could be cross-checked against the standard blocks are decoded.
+
+func init() {
+	RegisterVendorDecoder("WaveMTSParams", decodeWaveMTSParams)
+	RegisterVendorDecoder("JDSUEvenementsMTS", decodeJDSUEvents)
+	RegisterVendorDecoder("BlocOtdrPrivate", decodeBlocOtdrPrivate)
+	RegisterVendorDecoder("ActernaConfig", decodeActernaConfig)
+}
+
+// jdsuNoValue is the value stored by the JDSU platforms for a measurement which does not apply.
+const jdsuNoValue = -99999
+
+// WaveMTSParams is the link summary computed by the instrument, stored in the WaveMTSParams block.
+type WaveMTSParams struct {
+	EventCount  int     `json:"Event Count"`
+	FiberLength float64 `json:"Fiber Length(m)"`
+	Wavelength  float64 `json:"Wavelength(nm)"`
+	TotalLoss   float64 `json:"Total Loss(dB)"`
+	ORL         float64 `json:"ORL(dB)"`
+}
+
+func decodeWaveMTSParams(d *Trace, body []byte) (any, error) {
+	if len(body) < 404 {
+		return nil, ErrTruncatedBlock
+	}
+
+	f := func(offset int) float64 {
+		v := math.Float32frombits(binary.LittleEndian.Uint32(body[offset:]))
+		return math.Round(float64(v)*1000) / 1000
+	}
+
+	return WaveMTSParams{
+		EventCount:  int(f(372)),
+		FiberLength: f(376),
+		Wavelength:  f(380),
+		TotalLoss:   f(384),
+		ORL:         f(400),
+	}, nil
+}
+
+// JDSUEvents is the instrument's own analysis stored in the JDSUEvenementsMTS block.
+// It describes the link as events separated by fiber sections, including the small events below the reporting thresholds.
+type JDSUEvents struct {
+	Module   string        `json:"Module"`
+	Version  string        `json:"Version"`
+	ORL      float64       `json:"ORL(dB)"`
+	Events   []JDSUEvent   `json:"Events"`
+	Sections []JDSUSection `json:"Sections"`
+}
+
+// JDSUEvent is a reported event of the JDSUEvenementsMTS block.
+type JDSUEvent struct {
+	Distance       float64 `json:"Distance(m)"`
+	Loss           float64 `json:"Loss(dB)"`
+	Reflectance    float64 `json:"Reflectance(dB)"`
+	CumulativeLoss float64 `json:"Cumulative Loss(dB)"`
+}
+
+// JDSUSection is a stretch of fiber between two events, Loss is set on the small events the instrument merged into a section.
+type JDSUSection struct {
+	Start       float64 `json:"Start(m)"`
+	End         float64 `json:"End(m)"`
+	Attenuation float64 `json:"Attenuation(dB/km),omitempty"`
+	Loss        float64 `json:"Loss(dB),omitempty"`
+}
+
+// record kinds of the JDSUEvenementsMTS block.
+const (
+	jdsuSmallEvent = 2
+	jdsuEvent      = 3
+	jdsuSection    = 4
+	jdsuLink       = 5
+)
+
+func decodeJDSUEvents(d *Trace, body []byte) (any, error) {
+	const (
+		header = 78
+		size   = 140
+	)
+
+	// unlike the rest of the file, this block is big-endian.
+	if len(body) < header || binary.BigEndian.Uint32(body) != 1000 {
+		return nil, errors.New("unknown JDSUEvenementsMTS header")
+	}
+
+	count := int(binary.BigEndian.Uint16(body[14:]))
+	if len(body) < header+count*size {
+		return nil, ErrTruncatedBlock
+	}
+
+	value := func(record []byte, offset int) float64 {
+		v := math.Float64frombits(binary.BigEndian.Uint64(record[offset:]))
+		if v == jdsuNoValue {
+			return 0
+		}
+		return math.Round(v*10000) / 10000
+	}
+
+	e := JDSUEvents{
+		Module:  jdsuString(body[16:48]),
+		Version: jdsuString(body[48:64]),
+	}
+
+	for i := 0; i < count; i++ {
+		record := body[header+i*size : header+(i+1)*size]
+		if binary.BigEndian.Uint32(record) != 1001 {
+			return nil, errors.New("unknown JDSUEvenementsMTS record")
+		}
+
+		start := round(d.sampleDistance(int64(binary.BigEndian.Uint32(record[36:]))))
+		end := round(d.sampleDistance(int64(binary.BigEndian.Uint32(record[44:]))))
+
+		switch binary.BigEndian.Uint32(record[16:]) {
+		case jdsuEvent:
+			e.Events = append(e.Events, JDSUEvent{
+				Distance:       value(record, 108) * 1000,
+				Loss:           value(record, 52),
+				Reflectance:    value(record, 60),
+				CumulativeLoss: value(record, 76),
+			})
+		case jdsuSection:
+			e.Sections = append(e.Sections, JDSUSection{Start: start, End: end, Attenuation: value(record, 52)})
+		case jdsuSmallEvent:
+			e.Sections = append(e.Sections, JDSUSection{Start: start, End: end, Loss: value(record, 52)})
+		case jdsuLink:
+			e.ORL = value(record, 52)
+		}
+	}
+
+	return e, nil
+}
+
+// sampleDistance returns the distance of the sample with the given index, counted over the pulse width segments
+// which each have their own resolution.
+func (d *Trace) sampleDistance(index int64) float64 {
+	f := d.FixedParams
+	var distance float64
+	for i := 0; i < len(f.SampleQTY) && i < len(f.Resolution); i++ {
+		if index < f.SampleQTY[i] || i == len(f.SampleQTY)-1 || i == len(f.Resolution)-1 {
+			return distance + float64(index)*f.Resolution[i]
+		}
+		distance += float64(f.SampleQTY[i]) * f.Resolution[i]
+		index -= f.SampleQTY[i]
+	}
+	return distance
+}
+
+// jdsuString returns the NUL terminated string stored in a fixed size field.
+func jdsuString(b []byte) string {
+	if end := bytes.IndexByte(b, 0); end != -1 {
+		b = b[:end]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// SmartLink is the event table of the Smart Link view, stored as XML in the BlocOtdrPrivate block.
+// It holds the labels and pass/fail alarms the operator sees on the instrument.
+type SmartLink struct {
+	Unit   string           `json:"Unit"`
+	Events []SmartLinkEvent `json:"Events"`
+	Merged [][2]int         `json:"Merged Events,omitempty"`
+}
+
+// SmartLinkEvent is an entry of the Smart Link event table. Alarm is -1 when no threshold applies, 0 for a pass and 1 for a fail.
+type SmartLinkEvent struct {
+	Number   int     `json:"Event Number"`
+	Alarm    int     `json:"Alarm"`
+	Icon     int     `json:"Icon"`
+	Distance float64 `json:"Distance"`
+	Label    string  `json:"Label"`
+}
+
+func decodeBlocOtdrPrivate(d *Trace, body []byte) (any, error) {
+	start := bytes.Index(body, []byte("<smart_link"))
+	end := bytes.LastIndex(body, []byte("</smart_link>"))
+	if start == -1 || end < start {
+		return nil, errors.New("smart_link document not found")
+	}
+
+	var doc struct {
+		Table struct {
+			Unit   string `xml:"unit,attr"`
+			Events []struct {
+				Number   int     `xml:"no,attr"`
+				Alarm    int     `xml:"alarm,attr"`
+				Icon     int     `xml:"icon"`
+				Distance float64 `xml:"distance"`
+				Label    string  `xml:"label"`
+			} `xml:"event"`
+		} `xml:"event_table"`
+		Merged []struct {
+			First int `xml:"first_elt"`
+			Last  int `xml:"last_elt"`
+		} `xml:"merged_groupe>merged"`
+	}
+	if err := xml.Unmarshal(body[start:end+len("</smart_link>")], &doc); err != nil {
+		return nil, err
+	}
+
+	s := SmartLink{Unit: doc.Table.Unit}
+	for _, e := range doc.Table.Events {
+		s.Events = append(s.Events, SmartLinkEvent(e))
+	}
+	for _, m := range doc.Merged {
+		s.Merged = append(s.Merged, [2]int{m.First, m.Last})
+	}
+
+	return s, nil
+}
+
+// ActernaConfig is the instrument configuration stored in the ActernaConfig block as "OTDS:" remote commands.
+type ActernaConfig struct {
+	// Alarms holds the pass/fail thresholds, keyed by the OTDS:ALAR sub-command (SPLIC, REFL, CONN, ORLM...).
+	Alarms   map[string]string `json:"Alarms,omitempty"`
+	Commands []string          `json:"Commands"`
+}
+
+func decodeActernaConfig(d *Trace, body []byte) (any, error) {
+	c := ActernaConfig{}
+
+	for _, line := range strings.FieldsFunc(string(body), func(r rune) bool { return r == '\r' || r == '\n' || r == 0 }) {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "OTDS:") {
+			continue
+		}
+		c.Commands = append(c.Commands, line)
+
+		if alarm, ok := strings.CutPrefix(line, "OTDS:ALAR:"); ok {
+			key, value, _ := strings.Cut(alarm, " ")
+			if c.Alarms == nil {
+				c.Alarms = map[string]string{}
+			}
+			c.Alarms[key] = strings.TrimSpace(value)
+		}
+	}
+
+	if c.Commands == nil {
+		return nil, errors.New("no OTDS command found")
+	}
+
+	return c, nil
+}
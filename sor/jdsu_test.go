@@ -0,0 +1,93 @@
+package sor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJDSUBlocks(t *testing.T) {
+	tests := []struct {
+		file      string
+		wave      WaveMTSParams
+		module    string
+		orl       float64
+		events    int
+		section   JDSUSection
+		smartLink int
+		merged    [][2]int
+	}{
+		{
+			file:      "../sorfiles/2.sor",
+			wave:      WaveMTSParams{EventCount: 7, FiberLength: 59319, Wavelength: 1625, TotalLoss: 19.992, ORL: 30.639},
+			module:    "FO 1316",
+			orl:       30.6389,
+			events:    8,
+			section:   JDSUSection{Start: 570.423, End: 749.48, Attenuation: 0.2818},
+			smartLink: 7,
+		},
+		{
+			file:      "../sorfiles/3.sor",
+			wave:      WaveMTSParams{EventCount: 4, FiberLength: 6344, Wavelength: 1610, TotalLoss: 11.502, ORL: 15.978},
+			module:    "FO 1312",
+			orl:       15.9783,
+			events:    5,
+			section:   JDSUSection{Start: 494.324, End: 2354.594, Attenuation: 0.4843},
+			smartLink: 4,
+			merged:    [][2]int{{2, 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseFile(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if wave, ok := d.Vendor["WaveMTSParams"].(WaveMTSParams); !ok || wave != tt.wave {
+			t.Errorf("%s: WaveMTSParams %+v, want %+v", tt.file, d.Vendor["WaveMTSParams"], tt.wave)
+		}
+		if d.TotalLoss != tt.wave.TotalLoss {
+			t.Errorf("%s: total loss %v, want %v", tt.file, d.TotalLoss, tt.wave.TotalLoss)
+		}
+
+		events, ok := d.Vendor["JDSUEvenementsMTS"].(JDSUEvents)
+		if !ok {
+			t.Fatalf("%s: JDSUEvenementsMTS decoded as %T", tt.file, d.Vendor["JDSUEvenementsMTS"])
+		}
+		if events.Module != tt.module || events.ORL != tt.orl || len(events.Events) != tt.events {
+			t.Errorf("%s: module %q, ORL %v, %d events, want %q, %v and %d", tt.file, events.Module, events.ORL, len(events.Events), tt.module, tt.orl, tt.events)
+		}
+		// the last event closes the link at the total loss of the instrument.
+		if last := events.Events[len(events.Events)-1]; last.Distance != tt.wave.FiberLength || last.CumulativeLoss != tt.wave.TotalLoss {
+			t.Errorf("%s: last event %+v", tt.file, last)
+		}
+		if len(events.Sections) == 0 || events.Sections[0] != tt.section {
+			t.Errorf("%s: sections %+v, want %+v first", tt.file, events.Sections, tt.section)
+		}
+
+		smartLink, ok := d.Vendor["BlocOtdrPrivate"].(SmartLink)
+		if !ok || smartLink.Unit != "km" || len(smartLink.Events) != tt.smartLink || !reflect.DeepEqual(smartLink.Merged, tt.merged) {
+			t.Errorf("%s: BlocOtdrPrivate %+v", tt.file, d.Vendor["BlocOtdrPrivate"])
+		}
+
+		config, ok := d.Vendor["ActernaConfig"].(ActernaConfig)
+		if !ok || config.Alarms["SPLIC"] != "50" || config.Alarms["REFL"] != "-30" {
+			t.Errorf("%s: ActernaConfig alarms %v", tt.file, config.Alarms)
+		}
+	}
+}
+
+func TestSampleDistance(t *testing.T) {
+	d := &Trace{FixedParams: FixInfo{SampleQTY: []int64{100, 50}, Resolution: []float64{1, 4}}}
+
+	for _, tt := range []struct {
+		index int64
+		want  float64
+	}{
+		{0, 0}, {99, 99}, {100, 100}, {120, 180}, {160, 340},
+	} {
+		if got := d.sampleDistance(tt.index); got != tt.want {
+			t.Errorf("sampleDistance(%d) = %v, want %v", tt.index, got, tt.want)
+		}
+	}
+}
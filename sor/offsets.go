@@ -0,0 +1,84 @@
+package sor
+
+import (
+	"math"
+	"strings"
+)
+
+// Offsets are the distance shifts applied to the samples and the events, in metres.
+// The acquisition offset is the distance from the front panel to the first sample, and the user offset moves the
+// origin of the distances, for instance to the end of a launch cable.
+type Offsets struct {
+	Acquisition float64 `json:"Acquisition(m)"`
+	User        float64 `json:"User(m)"`
+	Corrected   bool    `json:"Corrected"`
+}
+
+// distanceUnits are the lengths in metres of the FxdParams distance units.
+var distanceUnits = map[string]float64{"km": 1000, "mt": 1, "ft": 0.3048, "mi": 1609.344}
+
+// getOffsets converts the FxdParams acquisition offset and the GenParams user offset to metres, Parse applies them
+// once the samples and the events are read.
+// The offsets are stored both as a time and as a distance, the time is used when set because it does not depend on the unit.
+func (d *Trace) getOffsets() error {
+	unit, ok := distanceUnits[strings.TrimSpace(d.FixedParams.Unit)]
+	if !ok {
+		unit = 1
+	}
+	toMetres := func(t, distance float64) float64 {
+		if t != 0 {
+			return t * math.Pow(10, -4) * d.FixedParams.FiberSpeed
+		}
+		// the distances are stored in tenths of the FxdParams unit, 2.sor stores its 81.6 km range as 816.
+		return distance / 10 * unit
+	}
+
+	d.Offsets = Offsets{
+		Acquisition: round(toMetres(d.FixedParams.AO, d.FixedParams.AOD)),
+		User:        round(toMetres(float64(d.GenParams.UserOffset), float64(d.GenParams.UserOffsetDistance))),
+	}
+
+	return nil
+}
+
+// SetCorrected switches the sample and event distances between the raw values stored in the file and the values
+// corrected with the acquisition and user offsets. Parse returns corrected distances.
+func (d *Trace) SetCorrected(corrected bool) {
+	if d.Offsets.Corrected == corrected {
+		return
+	}
+	d.Offsets.Corrected = corrected
+
+	// samples are counted from the first acquired point, events from the front panel.
+	samples := d.Offsets.Acquisition - d.Offsets.User
+	events := -d.Offsets.User
+	if !corrected {
+		samples, events = -samples, -events
+	}
+
+	if samples != 0 {
+		for _, p := range d.DataPoints {
+			p[0] = round(p[0] + samples)
+		}
+		for i := range d.Segments {
+			d.Segments[i].Start = round(d.Segments[i].Start + samples)
+			d.Segments[i].End = round(d.Segments[i].End + samples)
+		}
+	}
+
+	if events != 0 {
+		for i := range d.Events {
+			d.Events[i].EventLocM = round(d.Events[i].EventLocM + events)
+		}
+		s := &d.Summary
+		s.SpanStart, s.SpanEnd = round(s.SpanStart+events), round(s.SpanEnd+events)
+		s.ORLStart, s.ORLFinish = round(s.ORLStart+events), round(s.ORLFinish+events)
+	}
+
+	d.getFiberLength()
+}
+
+// round rounds a distance to the millimetre.
+func round(m float64) float64 {
+	return math.Round(m*1000) / 1000
+}
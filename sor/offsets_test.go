@@ -0,0 +1,94 @@
+package sor
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestOffsetDistanceUnits(t *testing.T) {
+	tests := []struct {
+		unit string
+		want float64
+	}{
+		{"km", 1200},
+		{"mt", 1.2},
+		{"ft", 0.366},
+		{"mi", 1931.213},
+		// an unknown unit is taken as metres.
+		{"", 1.2},
+	}
+
+	for _, tt := range tests {
+		d := &Trace{FixedParams: FixInfo{Unit: tt.unit, AOD: 12}, GenParams: GenParam{UserOffsetDistance: 12}}
+		if err := d.getOffsets(); err != nil {
+			t.Fatal(err)
+		}
+		if d.Offsets.Acquisition != tt.want || d.Offsets.User != tt.want {
+			t.Errorf("%q: offsets %v and %v m, want %v", tt.unit, d.Offsets.Acquisition, d.Offsets.User, tt.want)
+		}
+	}
+}
+
+func TestOffsetsApplied(t *testing.T) {
+	in, err := ParseFile("../sorfiles/2.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Offsets.Acquisition != 0 || in.Offsets.User != 0 {
+		t.Fatalf("2.sor has offsets %+v", in.Offsets)
+	}
+
+	// first sample 100 m and distance origin 30 m after the front panel.
+	unit := math.Pow(10, -4) * in.FixedParams.FiberSpeed
+	in.FixedParams.AO = math.Round(100 / unit)
+	in.GenParams.UserOffset = int32(math.Round(30 / unit))
+	d := roundTrip(t, in)
+
+	acquisition, user := round(in.FixedParams.AO*unit), round(float64(in.GenParams.UserOffset)*unit)
+	if d.Offsets != (Offsets{Acquisition: acquisition, User: user, Corrected: true}) {
+		t.Fatalf("offsets %+v, want %v and %v m", d.Offsets, acquisition, user)
+	}
+
+	for i, p := range d.DataPoints {
+		if want := round(in.DataPoints[i][0] + acquisition - user); p[0] != want {
+			t.Fatalf("sample %d at %v m, want %v", i, p[0], want)
+		}
+	}
+
+	// the events lie on the samples once corrected.
+	for _, e := range d.Events {
+		i := sort.Search(len(d.DataPoints), func(i int) bool { return d.DataPoints[i][0] >= e.EventLocM-0.001 })
+		if i == len(d.DataPoints) || math.Abs(d.DataPoints[i][0]-e.EventLocM) > 0.001 {
+			t.Errorf("event %d at %v m is not on a sample", e.EventNumber, e.EventLocM)
+		}
+	}
+
+	if want := round(in.Summary.SpanEnd - user); d.Summary.SpanEnd != want {
+		t.Errorf("span end %v m, want %v", d.Summary.SpanEnd, want)
+	}
+
+	// the corrected events are written back at the times they were read from.
+	written := roundTrip(t, d)
+	for i, e := range written.Events {
+		if e.EventLocM != d.Events[i].EventLocM {
+			t.Errorf("event %d at %v m after a round trip, want %v", e.EventNumber, e.EventLocM, d.Events[i].EventLocM)
+		}
+	}
+
+	events := append([]OTDREvent{}, d.Events...)
+	d.SetCorrected(false)
+	for i, p := range d.DataPoints {
+		if p[0] != in.DataPoints[i][0] {
+			t.Fatalf("raw sample %d at %v m, want %v", i, p[0], in.DataPoints[i][0])
+		}
+	}
+	for i, e := range d.Events {
+		if want := round(events[i].EventLocM + user); e.EventLocM != want {
+			t.Errorf("raw event %d at %v m, want %v", e.EventNumber, e.EventLocM, want)
+		}
+	}
+	if d.Summary != in.Summary {
+		t.Errorf("raw summary %+v, want %+v", d.Summary, in.Summary)
+	}
+}
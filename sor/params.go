@@ -0,0 +1,291 @@
+package sor
+
+import (
+	"encoding/hex"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParamsBlock is the content of the SetupParams, AcqParam, ViewParams, SystemParams and AnalysisParams blocks.
+// Their binary layout is vendor specific and not published, so the block is not decoded field by field: the readable
+// strings are extracted, the ones written as "name=value" or "name: value" are collected in Settings, and the settings
+// whose name is known are converted into the typed Acquisition, Analysis and View fields.
+type ParamsBlock struct {
+	Revision    int                  `json:"Revision"`
+	Acquisition *AcquisitionSettings `json:"Acquisition,omitempty"`
+	Analysis    *AnalysisSettings    `json:"Analysis,omitempty"`
+	View        *ViewSettings        `json:"View,omitempty"`
+	Strings     []string             `json:"Strings,omitempty"`
+	Settings    map[string]string    `json:"Settings,omitempty"`
+	// Raw is the hex dump of the block, set by Trace.IncludeRaw and always set for the scraped vendor blocks.
+	Raw string `json:"Raw,omitempty"`
+
+	body []byte
+}
+
+// AcquisitionSettings are the measurement settings found in a parameters block.
+type AcquisitionSettings struct {
+	Wavelength    float64 `json:"Wavelength(nm),omitempty"`
+	PulseWidth    float64 `json:"Pulse Width(ns),omitempty"`
+	Range         float64 `json:"Range(m),omitempty"`
+	Resolution    float64 `json:"Resolution(m),omitempty"`
+	AveragingTime float64 `json:"Averaging Time(s),omitempty"`
+	IOR           float64 `json:"IOR,omitempty"`
+}
+
+// AnalysisSettings are the event detection thresholds found in a parameters block.
+type AnalysisSettings struct {
+	SpliceLossThreshold  float64 `json:"Splice Loss Threshold(dB),omitempty"`
+	ReflectanceThreshold float64 `json:"Reflectance Threshold(dB),omitempty"`
+	EOFThreshold         float64 `json:"End Of Fiber Threshold(dB),omitempty"`
+	Backscattering       float64 `json:"Back-Scattering(dB),omitempty"`
+}
+
+// ViewSettings are the display window settings found in a parameters block.
+type ViewSettings struct {
+	Start float64 `json:"Start(m),omitempty"`
+	End   float64 `json:"End(m),omitempty"`
+	Scale float64 `json:"Scale(dB/div),omitempty"`
+}
+
+// The unit tables convert the values of the typed settings, the distances to metres, the pulse widths to nanoseconds
+// and the durations to seconds. The units are matched in lower case and a value without a unit is taken as is.
+var (
+	lengthUnits     = map[string]float64{"": 1, "m": 1, "km": 1000, "cm": 0.01, "mm": 0.001, "mi": 1609.344, "ft": 0.3048}
+	pulseUnits      = map[string]float64{"": 1, "ns": 1, "us": 1000, "µs": 1000, "ms": 1e6}
+	durationUnits   = map[string]float64{"": 1, "s": 1, "sec": 1, "ms": 0.001, "min": 60}
+	wavelengthUnits = map[string]float64{"": 1, "nm": 1}
+	dBUnits         = map[string]float64{"": 1, "db": 1}
+	indexUnits      = map[string]float64{"": 1}
+)
+
+// knownSetting is a typed field and the units its value may be written in.
+type knownSetting struct {
+	field func(p *ParamsBlock) *float64
+	units map[string]float64
+}
+
+// knownSettings maps the normalized setting names (lower case letters and digits only) to the typed field they fill.
+var knownSettings = map[string]knownSetting{
+	"wavelength":             {func(p *ParamsBlock) *float64 { return &p.acquisition().Wavelength }, wavelengthUnits},
+	"lambda":                 {func(p *ParamsBlock) *float64 { return &p.acquisition().Wavelength }, wavelengthUnits},
+	"pulsewidth":             {func(p *ParamsBlock) *float64 { return &p.acquisition().PulseWidth }, pulseUnits},
+	"pulse":                  {func(p *ParamsBlock) *float64 { return &p.acquisition().PulseWidth }, pulseUnits},
+	"range":                  {func(p *ParamsBlock) *float64 { return &p.acquisition().Range }, lengthUnits},
+	"distancerange":          {func(p *ParamsBlock) *float64 { return &p.acquisition().Range }, lengthUnits},
+	"acquisitionrange":       {func(p *ParamsBlock) *float64 { return &p.acquisition().Range }, lengthUnits},
+	"resolution":             {func(p *ParamsBlock) *float64 { return &p.acquisition().Resolution }, lengthUnits},
+	"samplingresolution":     {func(p *ParamsBlock) *float64 { return &p.acquisition().Resolution }, lengthUnits},
+	"averagingtime":          {func(p *ParamsBlock) *float64 { return &p.acquisition().AveragingTime }, durationUnits},
+	"acquisitiontime":        {func(p *ParamsBlock) *float64 { return &p.acquisition().AveragingTime }, durationUnits},
+	"duration":               {func(p *ParamsBlock) *float64 { return &p.acquisition().AveragingTime }, durationUnits},
+	"ior":                    {func(p *ParamsBlock) *float64 { return &p.acquisition().IOR }, indexUnits},
+	"refractiveindex":        {func(p *ParamsBlock) *float64 { return &p.acquisition().IOR }, indexUnits},
+	"groupindex":             {func(p *ParamsBlock) *float64 { return &p.acquisition().IOR }, indexUnits},
+	"splicelossthreshold":    {func(p *ParamsBlock) *float64 { return &p.analysis().SpliceLossThreshold }, dBUnits},
+	"splicethreshold":        {func(p *ParamsBlock) *float64 { return &p.analysis().SpliceLossThreshold }, dBUnits},
+	"lossthreshold":          {func(p *ParamsBlock) *float64 { return &p.analysis().SpliceLossThreshold }, dBUnits},
+	"reflectancethreshold":   {func(p *ParamsBlock) *float64 { return &p.analysis().ReflectanceThreshold }, dBUnits},
+	"reflectionthreshold":    {func(p *ParamsBlock) *float64 { return &p.analysis().ReflectanceThreshold }, dBUnits},
+	"reflthreshold":          {func(p *ParamsBlock) *float64 { return &p.analysis().ReflectanceThreshold }, dBUnits},
+	"eofthreshold":           {func(p *ParamsBlock) *float64 { return &p.analysis().EOFThreshold }, dBUnits},
+	"endoffiberthreshold":    {func(p *ParamsBlock) *float64 { return &p.analysis().EOFThreshold }, dBUnits},
+	"fiberendthreshold":      {func(p *ParamsBlock) *float64 { return &p.analysis().EOFThreshold }, dBUnits},
+	"backscatter":            {func(p *ParamsBlock) *float64 { return &p.analysis().Backscattering }, dBUnits},
+	"backscattering":         {func(p *ParamsBlock) *float64 { return &p.analysis().Backscattering }, dBUnits},
+	"backscattercoefficient": {func(p *ParamsBlock) *float64 { return &p.analysis().Backscattering }, dBUnits},
+	"viewstart":              {func(p *ParamsBlock) *float64 { return &p.view().Start }, lengthUnits},
+	"zoomstart":              {func(p *ParamsBlock) *float64 { return &p.view().Start }, lengthUnits},
+	"viewend":                {func(p *ParamsBlock) *float64 { return &p.view().End }, lengthUnits},
+	"zoomend":                {func(p *ParamsBlock) *float64 { return &p.view().End }, lengthUnits},
+	"dbdiv":                  {func(p *ParamsBlock) *float64 { return &p.view().Scale }, dBUnits},
+	"verticalscale":          {func(p *ParamsBlock) *float64 { return &p.view().Scale }, dBUnits},
+}
+
+func (p *ParamsBlock) acquisition() *AcquisitionSettings {
+	if p.Acquisition == nil {
+		p.Acquisition = &AcquisitionSettings{}
+	}
+	return p.Acquisition
+}
+
+func (p *ParamsBlock) analysis() *AnalysisSettings {
+	if p.Analysis == nil {
+		p.Analysis = &AnalysisSettings{}
+	}
+	return p.Analysis
+}
+
+func (p *ParamsBlock) view() *ViewSettings {
+	if p.View == nil {
+		p.View = &ViewSettings{}
+	}
+	return p.View
+}
+
+// The YokogawaSpecial and NokiaParams blocks are not decoded: nothing is known of their layout and no trace carrying
+// them was available, so their readable settings are scraped like the parameters blocks and their whole body is kept
+// in Raw, whether or not IncludeRaw is called.
+func init() {
+	for _, name := range []string{"YokogawaSpecial", "NokiaParams"} {
+		RegisterVendorDecoder(name, scrapeVendorBlock(name))
+	}
+}
+
+// scrapeVendorBlock returns a VendorDecoder reading the named block as a ParamsBlock with its hex dump.
+func scrapeVendorBlock(name string) VendorDecoder {
+	return func(d *Trace, body []byte) (any, error) {
+		b, _ := d.Block(name)
+		p := decodeParamsBlock(b.Revision, body)
+		p.Raw = hex.EncodeToString(body)
+		return p, nil
+	}
+}
+
+// getParamsBlocks decodes the vendor parameters blocks found in the file.
+// Like the proprietary blocks, they are optional and their layout is not published, so a block which cannot be read is
+// recorded as a warning instead of failing the parse.
+func (d *Trace) getParamsBlocks() error {
+	blocks := map[string]**ParamsBlock{
+		"SetupParams":    &d.SetupParams,
+		"AcqParam":       &d.AcqParams,
+		"ViewParams":     &d.ViewParams,
+		"SystemParams":   &d.SystemParams,
+		"AnalysisParams": &d.AnalysisParams,
+	}
+
+	for name, field := range blocks {
+		b, ok := d.Block(name)
+		if !ok {
+			continue
+		}
+
+		body, err := d.blockBody(name)
+		if err != nil {
+			d.Warnings = append(d.Warnings, err)
+			continue
+		}
+
+		*field = decodeParamsBlock(b.Revision, body)
+	}
+
+	return nil
+}
+
+func decodeParamsBlock(revision int, body []byte) *ParamsBlock {
+	p := &ParamsBlock{
+		Revision: revision,
+		Strings:  printableStrings(body, 3),
+		body:     body,
+	}
+
+	for _, s := range p.Strings {
+		key, value, ok := setting(s)
+		if !ok {
+			continue
+		}
+		if p.Settings == nil {
+			p.Settings = map[string]string{}
+		}
+		p.Settings[key] = value
+
+		if known, ok := knownSettings[normalize(key)]; ok {
+			if v, ok := quantity(value, known.units); ok {
+				*known.field(p) = v
+			}
+		}
+	}
+
+	return p
+}
+
+// setting splits a "name=value" or "name: value" string. The name must start with a letter, so that values such
+// as times ("12:30:45") are not taken for settings.
+func setting(s string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(s, "=")
+	if !ok {
+		key, value, ok = strings.Cut(s, ": ")
+	}
+	key = strings.TrimSpace(key)
+	if !ok || key == "" || !(key[0] >= 'a' && key[0] <= 'z' || key[0] >= 'A' && key[0] <= 'Z') {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// normalize lowers the setting name and drops everything but the letters and digits, so "Splice Loss Threshold",
+// "splice_loss_threshold" and "SpliceLossThreshold" are the same setting.
+func normalize(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, name)
+}
+
+var quantityPattern = regexp.MustCompile(`^([-+]?[0-9]*\.?[0-9]+(?:[eE][-+]?[0-9]+)?)\s*([a-zA-Zµ]*)`)
+
+// quantity parses a number followed by an optional unit, such as "1550 nm", "-65dB" or "10 km", and converts it with
+// the given unit table. A value written in a unit missing from the table is dropped.
+func quantity(value string, units map[string]float64) (float64, bool) {
+	m := quantityPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, false
+	}
+	factor, ok := units[strings.ToLower(m[2])]
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return math.Round(v*factor*1e6) / 1e6, true
+}
+
+// IncludeRaw sets the Raw field of the decoded parameters blocks, including the vendor ones, to the hex dump of
+// their body. It is left empty by Parse to keep the json export readable.
+func (d *Trace) IncludeRaw() {
+	blocks := []*ParamsBlock{d.SetupParams, d.AcqParams, d.ViewParams, d.SystemParams, d.AnalysisParams}
+	for _, v := range d.Vendor {
+		switch v := v.(type) {
+		case *ParamsBlock:
+			blocks = append(blocks, v)
+		}
+	}
+
+	for _, p := range blocks {
+		if p != nil {
+			p.Raw = hex.EncodeToString(p.body)
+		}
+	}
+}
+
+// printableStrings returns the runs of at least min printable ASCII characters found in b.
+func printableStrings(b []byte, min int) []string {
+	var out []string
+	start := -1
+
+	for i := 0; i <= len(b); i++ {
+		if i < len(b) && b[i] >= 0x20 && b[i] < 0x7f {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 {
+			if s := strings.TrimSpace(string(b[start:i])); len(s) >= min {
+				out = append(out, s)
+			}
+			start = -1
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,104 @@
+package sor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeParamsBlock(t *testing.T) {
+	body := []byte("\x01\x00Wavelength=1550 nm\x00\x10Pulse Width: 1 us\x00Range=20 km\x00AveragingTime=1 min\x00" +
+		"Splice_Loss_Threshold=0.05 dB\x00ReflectanceThreshold=-65dB\x00EOF Threshold=3.0\x00" +
+		"ZoomStart=100 m\x00ZoomEnd=2 km\x00Time: 12:30:45\x0012:30:45\x00")
+
+	p := decodeParamsBlock(210, body)
+
+	if want := (&AcquisitionSettings{Wavelength: 1550, PulseWidth: 1000, Range: 20000, AveragingTime: 60}); !reflect.DeepEqual(p.Acquisition, want) {
+		t.Errorf("Acquisition %+v, want %+v", p.Acquisition, want)
+	}
+	if want := (&AnalysisSettings{SpliceLossThreshold: 0.05, ReflectanceThreshold: -65, EOFThreshold: 3}); !reflect.DeepEqual(p.Analysis, want) {
+		t.Errorf("Analysis %+v, want %+v", p.Analysis, want)
+	}
+	if want := (&ViewSettings{Start: 100, End: 2000}); !reflect.DeepEqual(p.View, want) {
+		t.Errorf("View %+v, want %+v", p.View, want)
+	}
+
+	// a time is a value, not a setting named "12".
+	if p.Settings["Time"] != "12:30:45" {
+		t.Errorf("Time = %q, want 12:30:45", p.Settings["Time"])
+	}
+	if _, ok := p.Settings["12"]; ok {
+		t.Error("12:30:45 has been taken for a setting")
+	}
+
+	// the unit tables depend on the setting, and a value in an unknown unit is dropped.
+	for _, tt := range []struct {
+		setting string
+		want    *AcquisitionSettings
+	}{
+		{"AveragingTime=500 ms", &AcquisitionSettings{AveragingTime: 0.5}},
+		{"Pulse Width=500 ms", &AcquisitionSettings{PulseWidth: 5e8}},
+		{"Resolution=50 cm", &AcquisitionSettings{Resolution: 0.5}},
+		{"Range=3 furlongs", nil},
+		{"Wavelength=1550 km", nil},
+	} {
+		if got := decodeParamsBlock(210, []byte(tt.setting)).Acquisition; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Acquisition %+v, want %+v", tt.setting, got, tt.want)
+		}
+	}
+
+	if p.Raw != "" {
+		t.Error("Raw is set without IncludeRaw")
+	}
+	d := &Trace{AnalysisParams: p}
+	d.IncludeRaw()
+	if p.Raw == "" {
+		t.Error("Raw is not set by IncludeRaw")
+	}
+}
+
+func TestScrapeVendorBlocks(t *testing.T) {
+	body := []byte("\x07\x00\xffPulse Width=10 ns\x00\x01\x02")
+
+	for _, name := range []string{"YokogawaSpecial", "NokiaParams"} {
+		decode, ok := vendorDecoder(name)
+		if !ok {
+			t.Fatalf("no decoder registered for %s", name)
+		}
+		d := &Trace{Blocks: []Block{{Name: name, Revision: 100}}}
+		v, err := decode(d, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, ok := v.(*ParamsBlock)
+		if !ok {
+			t.Fatalf("%s decoded as %T", name, v)
+		}
+		if p.Revision != 100 || p.Acquisition == nil || p.Acquisition.PulseWidth != 10 {
+			t.Errorf("%s: revision %d, acquisition %+v", name, p.Revision, p.Acquisition)
+		}
+		// the bytes which are not part of a string are kept as well.
+		if want := "0700ff50756c73652057696474683d3130206e7300" + "0102"; p.Raw != want {
+			t.Errorf("%s: Raw %s, want %s", name, p.Raw, want)
+		}
+	}
+}
+
+func TestParamsBlockWarning(t *testing.T) {
+	// an AcqParam block whose name header is missing.
+	d := &Trace{
+		Blocks: []Block{{Name: "Map", Revision: 200}, {Name: "AcqParam", Offset: 0, Size: 12}},
+		raw:    []byte("SetupParams\x00"),
+	}
+
+	if err := d.getParamsBlocks(); err != nil {
+		t.Fatalf("getParamsBlocks() = %v, want a warning", err)
+	}
+	if d.AcqParams != nil {
+		t.Error("the malformed block has been decoded")
+	}
+	if len(d.Warnings) != 1 || !errors.Is(d.Warnings[0], ErrMalformedBlock) {
+		t.Errorf("warnings %v, want ErrMalformedBlock", d.Warnings)
+	}
+}
@@ -0,0 +1,87 @@
+package sor
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// reader decodes the little-endian values stored in a block body.
+// Reading past the end of the buffer sets err to ErrTruncatedBlock and returns zero values,
+// so a parser can read all of its fields and check for the error once.
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func newReader(buf []byte) *reader {
+	return &reader{buf: buf}
+}
+
+// next returns the following n bytes, or nil when the buffer is too short.
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.buf) {
+		r.err = ErrTruncatedBlock
+		r.pos = len(r.buf)
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) skip(n int) {
+	r.next(n)
+}
+
+func (r *reader) remaining() int {
+	return len(r.buf) - r.pos
+}
+
+func (r *reader) u16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *reader) i16() int16 {
+	return int16(r.u16())
+}
+
+func (r *reader) u32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *reader) i32() int32 {
+	return int32(r.u32())
+}
+
+// str reads a fixed length string.
+func (r *reader) str(n int) string {
+	return string(r.next(n))
+}
+
+// cstr reads a NUL terminated string and consumes the terminator.
+func (r *reader) cstr() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.buf[r.pos:], 0)
+	if end == -1 {
+		r.err = ErrTruncatedBlock
+		r.pos = len(r.buf)
+		return ""
+	}
+	s := string(r.buf[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
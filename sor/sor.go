@@ -0,0 +1,701 @@
+/*
+Author: Naseredin Aramnejad naseredin.aramnejad@gmail.com
+Package sor extracts all the possible information from the given sor file.
+each sor file (Provided by OTDR Equipment) contains multiple data blocks.
+Formulas and blueprint of this package are inspired by the information provided by:
+Sidney Li
+http://morethanfootnotes.blogspot.com/2015/07/
+*/
+
+package sor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lightSpeed = 299.79181901 // m/µsec
+
+func mod(a, b float64) float64 {
+	return a - b*math.Floor(a/b)
+}
+
+// dB converts a DataPts sample, the scale factor is 1000 for samples stored in 0.001 dB units.
+func dB(point uint16, scale int) float64 {
+	return float64(int64(point)*-int64(scale)) * 1e-6
+}
+
+// ParseFile opens the given sor file and parses it.
+func ParseFile(filename string) (*Trace, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	d.Filename = filename
+
+	return d, nil
+}
+
+// Parse reads a whole sor file from r and decodes all the supported blocks.
+// Missing optional blocks are recorded in Trace.Warnings, any other problem is returned as a *BlockError.
+func Parse(r io.Reader) (*Trace, error) {
+	buffer, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Trace{
+		raw: buffer,
+	}
+
+	if err := d.getMap(); err != nil {
+		return nil, err
+	}
+
+	steps := []func() error{
+		d.getBellCoreVersion,
+		d.getSupParams,
+		d.getChecksum,
+		d.getGenParams,
+		d.getFixedParams,
+		d.getOffsets,
+		d.getDataPoints,
+		d.getKeyEvents,
+		d.getMiscParams,
+		d.getParamsBlocks,
+		d.getVendorBlocks,
+		d.getTotalLoss,
+	}
+
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+
+	d.SetCorrected(true)
+
+	return d, nil
+}
+
+// ReadBlocks decodes only the Map block directory of a sor file, so the blocks of a file which does not parse can still be inspected.
+func ReadBlocks(r io.Reader) ([]Block, error) {
+	buffer, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Trace{
+		raw: buffer,
+	}
+
+	if err := d.getMap(); err != nil {
+		return nil, err
+	}
+
+	return d.Blocks, nil
+}
+
+// getMap decodes the Map block directory and computes the offset of every block listed in it.
+func (d *Trace) getMap() error {
+	r := newReader(d.raw)
+
+	// Version 2 files start every block, the Map included, with the block name.
+	named := bytes.HasPrefix(d.raw, []byte("Map\x00"))
+	if named {
+		r.skip(4)
+	}
+
+	mapBlock := Block{
+		Name:     "Map",
+		Revision: int(r.u16()),
+		Size:     int(r.u32()),
+	}
+	blockQTY := int(r.u16())
+	header := r.pos
+
+	if !named && mapBlock.Revision >= 200 {
+		return missing("Map")
+	}
+
+	if r.err != nil || mapBlock.Size > len(d.raw) {
+		return truncated("Map")
+	}
+
+	r = newReader(d.raw[:mapBlock.Size])
+	r.skip(header)
+
+	mapBlock.Data = d.raw[:mapBlock.Size]
+	d.Blocks = []Block{mapBlock}
+	offset := mapBlock.Size
+
+	for i := 1; i < blockQTY; i++ {
+		b := Block{
+			Name:     r.cstr(),
+			Revision: int(r.u16()),
+			Size:     int(r.u32()),
+			Offset:   offset,
+		}
+		if r.err != nil {
+			return truncated("Map")
+		}
+
+		if b.Offset+b.Size <= len(d.raw) {
+			b.Data = d.raw[b.Offset : b.Offset+b.Size]
+		}
+
+		d.Blocks = append(d.Blocks, b)
+		offset += b.Size
+	}
+
+	return nil
+}
+
+// Block returns the Map directory entry of the named block.
+func (d *Trace) Block(name string) (Block, bool) {
+	for _, b := range d.Blocks {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return Block{}, false
+}
+
+// blockBody returns the given block without its name header.
+func (d *Trace) blockBody(name string) ([]byte, error) {
+	b, ok := d.Block(name)
+	if !ok {
+		return nil, missing(name)
+	}
+
+	if b.Offset+b.Size > len(d.raw) {
+		return nil, truncated(name)
+	}
+
+	body := d.raw[b.Offset : b.Offset+b.Size]
+	if d.version() < 2 {
+		return body, nil
+	}
+
+	if !bytes.HasPrefix(body, append([]byte(name), 0)) {
+		return nil, &BlockError{Block: name, Err: fmt.Errorf("%w: block header not found at offset %d", ErrMalformedBlock, b.Offset)}
+	}
+
+	return body[len(name)+1:], nil
+}
+
+// blockText returns the body of the given block as a text string.
+func (d *Trace) blockText(name string) (string, error) {
+	body, err := d.blockBody(name)
+	return string(body), err
+}
+
+// version returns the major Bellcore version of the file, taken from the Map revision.
+func (d *Trace) version() int {
+	m, _ := d.Block("Map")
+	return m.Revision / 100
+}
+
+// Segment returns the pulse width segment covering the given distance. Distances past the end of the trace
+// belong to the last segment.
+func (d *Trace) Segment(distance float64) (Segment, bool) {
+	for i, s := range d.Segments {
+		if distance < s.End || i == len(d.Segments)-1 {
+			return s, true
+		}
+	}
+	return Segment{}, false
+}
+
+// Event returns the event with the given number.
+func (d *Trace) Event(number int) (OTDREvent, bool) {
+	for _, e := range d.Events {
+		if e.EventNumber == number {
+			return e, true
+		}
+	}
+	return OTDREvent{}, false
+}
+
+// hasBlock reports whether the block is listed in the Map.
+func (d *Trace) hasBlock(name string) bool {
+	_, ok := d.Block(name)
+	return ok
+}
+
+// Under construction
+func (d *Trace) getMiscParams() error {
+	m := MiscParams{}
+
+	if !d.hasBlock("MiscParams") {
+		return nil
+	}
+
+	miscParams, err := d.blockText("MiscParams")
+	if err != nil {
+		return err
+	}
+
+	slicedMiscParams := strings.Split(miscParams, "\x00")
+
+	m.Mode = extractData(slicedMiscParams, 0)
+	m.FiberType = extractData(slicedMiscParams, 1)
+
+	d.MiscParams = m
+	return nil
+}
+
+// getFixedParams function extracts the Fixed Parameters from the sor file and stores it in FixInfos struct.
+func (d *Trace) getFixedParams() error {
+
+	f := FixInfo{}
+
+	if !d.hasBlock("FxdParams") {
+		return missing("FxdParams")
+	}
+
+	fixInfo, err := d.blockBody("FxdParams")
+	if err != nil {
+		return err
+	}
+
+	r := newReader(fixInfo)
+
+	// the timestamp is stored as seconds since the epoch, it is kept in UTC so that it does not depend on the machine.
+	f.DateTime = time.Unix(int64(r.u32()), 0).UTC()
+	unit := r.str(2)
+	f.ActualWL = float64(r.u16()) / 10.0
+	f.AO = float64(r.i32())
+	if d.version() >= 2 {
+		f.AOD = float64(r.i32())
+	}
+	f.PulseWidthNo = int64(r.u16())
+
+	if r.err != nil || r.remaining() < int(f.PulseWidthNo)*10 {
+		return truncated("FxdParams")
+	}
+
+	for i := 0; i < int(f.PulseWidthNo); i++ {
+		f.PulseWidth = append(f.PulseWidth, int64(r.u16()))
+	}
+
+	resolution_m_p1 := []float64{}
+	for i := 0; i < int(f.PulseWidthNo); i++ {
+		resolution_m_p1 = append(resolution_m_p1, float64(r.u32())*math.Pow(10, -8))
+	}
+
+	for i := 0; i < int(f.PulseWidthNo); i++ {
+		f.SampleQTY = append(f.SampleQTY, int64(r.u32()))
+	}
+
+	f.IOR = float64(r.u32())
+
+	f.RefIndex = f.IOR * math.Pow(10, -5)
+
+	f.FiberSpeed = lightSpeed / f.RefIndex
+
+	for i := 0; i < int(f.PulseWidthNo); i++ {
+		f.Resolution = append(f.Resolution, resolution_m_p1[i]*f.FiberSpeed)
+	}
+
+	f.Backscattering = float64(r.u16()) * -0.1
+	f.Averaging = int64(r.u32())
+	if d.version() >= 2 {
+		f.AveragingTime = float64(r.u16()) / 600
+	}
+
+	if r.err != nil {
+		return truncated("FxdParams")
+	}
+
+	for i := 0; i < int(f.PulseWidthNo); i++ {
+		f.Range = append(f.Range, float64(f.SampleQTY[i])*f.Resolution[i])
+	}
+
+	f.Unit = string(unit)
+
+	// an instrument whose clock was never set stores 0, a date ahead of the current time is a wrong clock as well.
+	if f.DateTime.Unix() == 0 || f.DateTime.After(time.Now().Add(24*time.Hour)) {
+		d.Warnings = append(d.Warnings, &BlockError{Block: "FxdParams", Err: fmt.Errorf("%w: %s", ErrImplausibleDate, f.DateTime.Format(time.RFC3339))})
+	}
+
+	// the analysis settings at the end of the block are left out by some instruments, a short block keeps the acquisition.
+	tail := 20
+	if d.version() >= 2 {
+		tail += 4 + 2 + 16
+	}
+	if r.remaining() < tail {
+		d.Warnings = append(d.Warnings, &BlockError{Block: "FxdParams", Err: fmt.Errorf("%w: the analysis settings are missing", ErrTruncatedBlock)})
+		d.FixedParams = f
+		return nil
+	}
+
+	f.AR = float64(r.i32())
+	if d.version() >= 2 {
+		f.ARD = float64(r.i32())
+	}
+	f.FPO = float64(r.i32())
+	f.NoiseFloor = -float64(r.u16()) / 1000
+	f.NoiseFloorScale = float64(r.i16()) / 1000
+	f.PowerOffset = float64(r.u16()) / 1000
+	f.LossThreshold = float64(r.u16()) / 1000
+	f.ReflThreshold = -float64(r.u16()) / 1000
+	f.EOFThreshold = float64(r.u16()) / 1000
+	if d.version() >= 2 {
+		f.TraceType = r.str(2)
+		for i := range f.Window {
+			f.Window[i] = int64(r.i32())
+		}
+	}
+
+	d.FixedParams = f
+	return nil
+}
+
+func (d *Trace) getDataPoints() error {
+
+	if !d.hasBlock("DataPts") {
+		return missing("DataPts")
+	}
+
+	dtpoints, err := d.blockBody("DataPts")
+	if err != nil {
+		return err
+	}
+	r := newReader(dtpoints)
+	info := DataPtsInfo{Points: int(r.u32())}
+	traces := int(r.u16())
+	if r.err != nil || r.remaining() < traces*6 {
+		return truncated("DataPts")
+	}
+
+	sum := 0
+	for i := 0; i < traces; i++ {
+		t := DataPtsTrace{Points: int(r.u32()), ScaleFactor: int(r.u16())}
+		info.Traces = append(info.Traces, t)
+		sum += t.Points
+	}
+	d.DataPtsInfo = info
+	dtpoints = dtpoints[r.pos:]
+
+	var samples int64
+	for _, qty := range d.FixedParams.SampleQTY {
+		samples += qty
+	}
+
+	// the counts are only checked, the samples are decoded along the FxdParams segments up to the end of the block so
+	// that a trace with an inconsistent header can still be drawn. Each mismatch is reported in Warnings.
+	if sum != info.Points {
+		d.Warnings = append(d.Warnings, &BlockError{Block: "DataPts", Err: fmt.Errorf("%w: %d points announced, the traces hold %d", ErrMalformedBlock, info.Points, sum)})
+	}
+	if int64(info.Points) != samples {
+		d.Warnings = append(d.Warnings, &BlockError{Block: "DataPts", Err: fmt.Errorf("%w: %d points, FxdParams announces %d samples", ErrMalformedBlock, info.Points, samples)})
+	}
+	if len(dtpoints) < info.Points*2 {
+		d.Warnings = append(d.Warnings, &BlockError{Block: "DataPts", Err: fmt.Errorf("%w: %d points announced, %d stored", ErrTruncatedBlock, info.Points, len(dtpoints)/2)})
+	}
+
+	// scale returns the scale factor of the trace holding the given point.
+	scale := func(index int64) int {
+		s := 1000
+		for _, t := range info.Traces {
+			s = t.ScaleFactor
+			if index < int64(t.Points) {
+				break
+			}
+			index -= int64(t.Points)
+		}
+		return s
+	}
+
+	var start int64 = 0
+	var cumulative_length float64 = 0
+
+	// all the points share one backing array instead of allocating a slice per sample.
+	total := len(dtpoints) / 2
+	values := make([]float64, 0, total*2)
+	d.DataPoints = make([][]float64, 0, total)
+	d.Segments = nil
+
+	for i := range d.FixedParams.SampleQTY {
+
+		qty := int64(d.FixedParams.SampleQTY[i])
+		resolution := d.FixedParams.Resolution[i]
+
+		segment := Segment{
+			PulseWidth: d.FixedParams.PulseWidth[i],
+			Resolution: resolution,
+			SampleQTY:  qty,
+			Start:      math.Round(cumulative_length*1000) / 1000,
+		}
+		first := len(d.DataPoints)
+
+		// the samples announced past the end of the block are not stored, the loop stops with the block.
+		stored := min(qty, max(int64(total)-start, 0))
+
+		var j int64
+		for j = 0; j < stored; j++ {
+			index := start + j
+			db_value := math.Round(dB(binary.LittleEndian.Uint16(dtpoints[index*2:]), scale(index))*1000) / 1000
+			passedlen := math.Round(float64(cumulative_length*1000)) / 1000
+			values = append(values, passedlen, db_value)
+			d.DataPoints = append(d.DataPoints, values[len(values)-2:len(values):len(values)])
+			cumulative_length += resolution
+		}
+		start += qty
+
+		segment.DataPoints = d.DataPoints[first:len(d.DataPoints):len(d.DataPoints)]
+		segment.End = math.Round(cumulative_length*1000) / 1000
+		d.Segments = append(d.Segments, segment)
+	}
+	return nil
+}
+
+// SupParams function extracts the Supplier Parameters from the sor file and stores it in SupParam struct.
+func (d *Trace) getSupParams() error {
+
+	if !d.hasBlock("SupParams") {
+		d.Warnings = append(d.Warnings, missing("SupParams"))
+		return nil
+	}
+
+	supParams, err := d.blockText("SupParams")
+	if err != nil {
+		return err
+	}
+
+	supString := strings.Split(supParams, "\x00")
+	slicedParams := supString[:len(supString)-1]
+
+	field := func(item int) string {
+		if item < len(slicedParams) {
+			return slicedParams[item]
+		}
+		return ""
+	}
+
+	d.padded.Supplier = SupParam{
+		OTDRSupplier:   field(0),
+		OTDRName:       field(1),
+		OTDRsn:         field(2),
+		OTDRModuleName: field(3),
+		OTDRModuleSN:   field(4),
+		OTDRswVersion:  field(5),
+		OTDROtherInfo:  field(6),
+	}
+
+	supInfo := SupParam{
+		OTDRSupplier:   extractData(slicedParams, 0),
+		OTDRName:       extractData(slicedParams, 1),
+		OTDRsn:         extractData(slicedParams, 2),
+		OTDRModuleName: extractData(slicedParams, 3),
+		OTDRModuleSN:   extractData(slicedParams, 4),
+		OTDRswVersion:  extractData(slicedParams, 5),
+		OTDROtherInfo:  extractData(slicedParams, 6),
+	}
+
+	d.Supplier = supInfo
+	return nil
+}
+
+func extractData(data []string, item int) string {
+	if len(data)-1 < item {
+		return ""
+	} else {
+		return strings.TrimSpace(data[item])
+	}
+}
+
+// GenParams function extracts the General Parameters from the sor file and stores it in GenParam struct.
+// Version 1 files have no fiber type and user offset distance fields.
+func (d *Trace) getGenParams() error {
+
+	if !d.hasBlock("GenParams") {
+		d.Warnings = append(d.Warnings, missing("GenParams"))
+		return nil
+	}
+
+	genParams, err := d.blockBody("GenParams")
+	if err != nil {
+		return err
+	}
+
+	r := newReader(genParams)
+
+	genInfo := GenParam{}
+	genInfo.Lang = r.str(2)
+	genInfo.CableID = r.cstr()
+	genInfo.FiberID = r.cstr()
+	if d.version() >= 2 {
+		if fiberType := r.u16(); fiberType != 0 {
+			genInfo.FiberType = "G." + strconv.Itoa(int(fiberType))
+		}
+	}
+	genInfo.OTDRWavelength = strconv.Itoa(int(r.u16())) + " nm"
+	genInfo.LocationA = r.cstr()
+	genInfo.LocationB = r.cstr()
+	genInfo.CableCode = r.cstr()
+	genInfo.BuildCondition = r.str(2)
+	genInfo.UserOffset = r.i32()
+	if d.version() >= 2 {
+		genInfo.UserOffsetDistance = r.i32()
+	}
+	genInfo.Operator = r.cstr()
+	genInfo.Comment = r.cstr()
+
+	if r.err != nil {
+		return truncated("GenParams")
+	}
+
+	d.padded.GenParams = genInfo
+	for _, s := range []*string{&genInfo.CableID, &genInfo.FiberID, &genInfo.LocationA, &genInfo.LocationB, &genInfo.CableCode, &genInfo.Operator, &genInfo.Comment} {
+		*s = strings.TrimSpace(*s)
+	}
+
+	d.GenParams = genInfo
+	return nil
+}
+
+// getFiberLength calculates the fiber length and returns it.
+// Events are sorted by distance, so the farthest end of fiber event wins when there are several.
+func (d *Trace) getFiberLength() {
+	for _, v := range d.Events {
+		if v.Code.EndOfFiber {
+			d.TotalLength = float64(v.EventLocM)
+		}
+	}
+}
+
+// getBellCoreVersion reads the bellcore version from the sor file and returns it.
+func (d *Trace) getBellCoreVersion() error {
+
+	m, _ := d.Block("Map")
+	d.BellCoreVersion = float64(m.Revision) / 100.0
+	return nil
+}
+
+// getTotalLoss sets the total loss of the fiber, taken from the KeyEvents summary.
+// Some instruments leave it empty and only store it in their own blocks, which are used as a fallback.
+func (d *Trace) getTotalLoss() error {
+	d.TotalLoss = d.Summary.TotalLoss
+
+	if w, ok := d.Vendor["WaveMTSParams"].(WaveMTSParams); ok && d.TotalLoss == 0 {
+		d.TotalLoss = w.TotalLoss
+	}
+	return nil
+}
+
+// getLinkSummary decodes the KeyEvents trailer which follows the last event.
+func (d *Trace) getLinkSummary(trailer []byte) error {
+	r := newReader(trailer)
+
+	distance := func(t float64) float64 {
+		return math.Round(t*math.Pow(10, -4)*d.FixedParams.FiberSpeed*1000) / 1000
+	}
+
+	d.Summary = LinkSummary{
+		TotalLoss: float64(r.i32()) * 0.001,
+		SpanStart: distance(float64(r.i32())),
+		SpanEnd:   distance(float64(r.u32())),
+		ORL:       float64(r.u16()) * 0.001,
+		ORLStart:  distance(float64(r.i32())),
+		ORLFinish: distance(float64(r.u32())),
+	}
+
+	if r.err != nil {
+		return truncated("KeyEvents")
+	}
+	return nil
+}
+
+// getKeyEvents function extracts the events information from the sor file and stores it in OTDREvent struct.
+// Every event is a run of fixed size fields followed by a NUL terminated comment, and the link summary follows the last one.
+func (d *Trace) getKeyEvents() error {
+
+	d.Events = nil
+
+	if !d.hasBlock("KeyEvents") {
+		d.Warnings = append(d.Warnings, missing("KeyEvents"))
+		return nil
+	}
+
+	events, err := d.blockBody("KeyEvents")
+	if err != nil {
+		return err
+	}
+
+	r := newReader(events)
+	evnumbers := int(r.u16())
+
+	for i := 1; i <= evnumbers; i++ {
+		event := d.readEvent(r)
+
+		// version 1 events have no marker fields.
+		if d.version() >= 2 {
+			event.EndOfPreviousEvent = int(r.u32())
+			event.BegOfCurrentEvent = int(r.u32())
+			event.EndOfCurrentEvent = int(r.u32())
+			event.BegOfNextEvent = int(r.u32())
+			event.PeakCurrentEvent = int(r.u32())
+		}
+		event.Comment = strings.TrimSpace(r.cstr())
+
+		if r.err != nil {
+			return &BlockError{Block: "KeyEvents", Err: fmt.Errorf("%w: event %d of %d", ErrTruncatedBlock, i, evnumbers)}
+		}
+		d.Events = append(d.Events, event)
+	}
+
+	sort.SliceStable(d.Events, func(i, j int) bool {
+		if d.Events[i].EventLocM != d.Events[j].EventLocM {
+			return d.Events[i].EventLocM < d.Events[j].EventLocM
+		}
+		return d.Events[i].EventNumber < d.Events[j].EventNumber
+	})
+
+	return d.getLinkSummary(r.next(22))
+}
+
+// readEvent reads the event fields shared by all the Bellcore versions.
+func (d *Trace) readEvent(r *reader) OTDREvent {
+	event := OTDREvent{}
+	event.EventNumber = int(r.u16())
+
+	event.EventLocM = float64(r.u32()) * (math.Pow(10, -4)) * float64(d.FixedParams.FiberSpeed)
+
+	// snap the event to the sample grid of the segment it lies in. The events are measured from the front panel and
+	// the samples from the first acquired point, the acquisition offset further.
+	first := d.Offsets.Acquisition
+	if segment, ok := d.Segment(event.EventLocM - first); ok && segment.Resolution > 0 {
+		stValue := mod(event.EventLocM-first-segment.Start, segment.Resolution)
+		if stValue >= segment.Resolution/2 {
+			event.EventLocM = event.EventLocM + (segment.Resolution - stValue)
+		} else {
+			event.EventLocM = event.EventLocM + -stValue
+		}
+	}
+
+	event.EventLocM = math.Round(event.EventLocM*1000) / 1000
+
+	event.Slope = float64(r.i16()) * 0.001
+	event.SpliceLoss = float64(r.i16()) * 0.001
+	event.RefLoss = float64(r.i32()) * 0.001
+	event.EventType = r.str(8)
+	event.Code = ParseEventCode(event.EventType)
+
+	return event
+}
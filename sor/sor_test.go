@@ -0,0 +1,149 @@
+package sor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestParseVersions parses the version 1 fixture, which holds the standard blocks of 2.sor in the version 1 layout
+// (see sorfiles/mkv1.go), and compares it with the version 2 original.
+func TestParseVersions(t *testing.T) {
+	v1, err := ParseFile("../sorfiles/v1.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := ParseFile("../sorfiles/2.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v1.BellCoreVersion != 1 || v2.BellCoreVersion != 2.1 {
+		t.Errorf("versions %v and %v, want 1 and 2.1", v1.BellCoreVersion, v2.BellCoreVersion)
+	}
+	if v1.Checksum.Status != ChecksumValid {
+		t.Errorf("version 1 checksum %s, want %s", v1.Checksum.Status, ChecksumValid)
+	}
+
+	// the fields missing from version 1.
+	g := v2.GenParams
+	g.FiberType, g.UserOffsetDistance = "", 0
+	f := v2.FixedParams
+	f.AOD, f.AveragingTime, f.ARD, f.TraceType, f.Window = 0, 0, 0, "", [4]int64{}
+
+	for _, field := range []struct {
+		name   string
+		v1, v2 any
+	}{
+		{"GenParams", v1.GenParams, g},
+		{"Supplier", v1.Supplier, v2.Supplier},
+		{"FixedParams", v1.FixedParams, f},
+		{"DataPoints", v1.DataPoints, v2.DataPoints},
+		{"Segments", v1.Segments, v2.Segments},
+		{"Summary", v1.Summary, v2.Summary},
+	} {
+		if !reflect.DeepEqual(field.v1, field.v2) {
+			t.Errorf("%s differs:\n%+v\n%+v", field.name, field.v1, field.v2)
+		}
+	}
+
+	if len(v1.Events) != len(v2.Events) {
+		t.Fatalf("%d events, want %d", len(v1.Events), len(v2.Events))
+	}
+	for i, e := range v1.Events {
+		want := v2.Events[i]
+		want.EndOfPreviousEvent, want.BegOfCurrentEvent, want.EndOfCurrentEvent, want.BegOfNextEvent, want.PeakCurrentEvent = 0, 0, 0, 0, 0
+		if !reflect.DeepEqual(e, want) {
+			t.Errorf("event %d differs:\n%+v\n%+v", i, e, want)
+		}
+	}
+}
+
+func TestDataPointsWarnings(t *testing.T) {
+	raw, err := os.ReadFile("../sorfiles/2.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := ReadBlocks(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// announce one point more than the traces, FxdParams and the block hold.
+	for _, b := range blocks {
+		if b.Name == "DataPts" {
+			p := raw[b.Offset+len("DataPts\x00"):]
+			binary.LittleEndian.PutUint32(p, binary.LittleEndian.Uint32(p)+1)
+		}
+	}
+
+	d, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var malformed, truncated int
+	for _, w := range d.Warnings {
+		switch {
+		case errors.Is(w, ErrMalformedBlock):
+			malformed++
+		case errors.Is(w, ErrTruncatedBlock):
+			truncated++
+		}
+	}
+	if malformed != 2 || truncated != 1 {
+		t.Errorf("%d malformed and %d truncated warnings, want 2 and 1: %v", malformed, truncated, d.Warnings)
+	}
+}
+
+func TestDataPointsOversizedCount(t *testing.T) {
+	in, err := ParseFile("../sorfiles/2.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// FxdParams announces far more samples than the block holds, only the stored ones are read.
+	in.FixedParams.SampleQTY[0] = 0x7fffffff
+	var buf bytes.Buffer
+	if err := Write(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	d, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Parse took %v", elapsed)
+	}
+	if len(d.DataPoints) != len(in.DataPoints) {
+		t.Errorf("%d points, want %d", len(d.DataPoints), len(in.DataPoints))
+	}
+}
+
+// BenchmarkParse measures the decoding of the sample traces, read once so that the disk is left out.
+func BenchmarkParse(b *testing.B) {
+	for _, name := range []string{"2.sor", "3.sor"} {
+		raw, err := os.ReadFile(filepath.Join("..", "sorfiles", name))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(raw)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := Parse(bytes.NewReader(raw)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package sor
+
+import "time"
+
+// Trace is the decoded content of a sor file.
+type Trace struct {
+	Filename        string       `json:"File Name"`
+	FixedParams     FixInfo      `json:"Fixed Parameters"`
+	TotalLoss       float64      `json:"Total Fiber Loss(dB)"`
+	TotalLength     float64      `json:"Fiber Length(km)"`
+	GenParams       GenParam     `json:"General Information"`
+	Supplier        SupParam     `json:"Supplier Information"`
+	Events          []OTDREvent  `json:"Key Events"`
+	Summary         LinkSummary  `json:"Link Summary"`
+	Offsets         Offsets      `json:"Distance Offsets"`
+	BellCoreVersion float64      `json:"Bellcore Version"`
+	Blocks          []Block      `json:"Blocks"`
+	Checksum        Checksum     `json:"Checksum"`
+	DataPoints      [][]float64  `json:"-"`
+	Segments        []Segment    `json:"Segments"`
+	DataPtsInfo     DataPtsInfo  `json:"Data Points Info"`
+	MiscParams      MiscParams   `json:"Misc Params"`
+	SetupParams     *ParamsBlock `json:"Setup Params,omitempty"`
+	AcqParams       *ParamsBlock `json:"Acquisition Params,omitempty"`
+	ViewParams      *ParamsBlock `json:"View Params,omitempty"`
+	SystemParams    *ParamsBlock `json:"System Params,omitempty"`
+	AnalysisParams  *ParamsBlock `json:"Analysis Params,omitempty"`
+
+	// Vendor holds the proprietary blocks decoded by the registered VendorDecoder, keyed by block name.
+	Vendor map[string]any `json:"Vendor,omitempty"`
+
+	// Warnings holds the non-fatal problems found while parsing, such as optional blocks missing from the file.
+	Warnings []error `json:"-"`
+
+	raw []byte
+	// padded holds the GenParams and SupParams strings as stored, before the surrounding spaces were trimmed.
+	padded struct {
+		GenParams GenParam
+		Supplier  SupParam
+	}
+}
+
+// Block is an entry of the Map block directory.
+type Block struct {
+	Name     string `json:"Name"`
+	Revision int    `json:"Revision"`
+	Size     int    `json:"Size"`
+	Offset   int    `json:"Offset"`
+
+	// Data is the whole block as stored in the file, nil when the file is shorter than the Map claims.
+	Data []byte `json:"-"`
+}
+
+type MiscParams struct {
+	Mode      string `json:"Scan Mode"`
+	FiberType string `json:"Fiber Type"`
+}
+
+// SupParam is the Supplier Parameters extracted from the sor file.
+type SupParam struct {
+	OTDRSupplier   string `json:"OTDR Supplier"`
+	OTDRName       string `json:"OTDR Name"`
+	OTDRsn         string `json:"OTDR SN"`
+	OTDRModuleName string `json:"OTDR Module Name"`
+	OTDRModuleSN   string `json:"OTDR Module SN"`
+	OTDRswVersion  string `json:"OTDR SW Version"`
+	OTDROtherInfo  string `json:"OTDR Other Info"`
+}
+
+// GenParams is the General Parameters extracted from the sor file.
+type GenParam struct {
+	CableID        string `json:"Cable Id"`
+	Lang           string `json:"Language"`
+	FiberID        string `json:"Fiber Id"`
+	LocationA      string `json:"Location A"`
+	LocationB      string `json:"Location B"`
+	BuildCondition string `json:"Build Condition"`
+	Comment        string `json:"Comment"`
+	CableCode      string `json:"Cable Code"`
+	Operator       string `json:"Operator"`
+	FiberType      string `json:"Fiber Type"`
+	OTDRWavelength string `json:"OTDR Wavelength"`
+	// UserOffset is the time offset (100 ps units) entered by the user, UserOffsetDistance is the same offset in 10x distance units.
+	UserOffset         int32 `json:"User Offset"`
+	UserOffsetDistance int32 `json:"User Offset Distance"`
+}
+
+// OTDREvent is the event information extracted from the sor file.
+type OTDREvent struct {
+	EventType          string    `json:"Event Type"`
+	Code               EventCode `json:"Event Code"`
+	EventLocM          float64   `json:"Event Point(m)"`
+	EventNumber        int       `json:"Event Number"`
+	Slope              float64   `json:"Slope(dB)"`
+	SpliceLoss         float64   `json:"Splice Loss(dB)"`
+	RefLoss            float64   `json:"Reflection Loss(dB)"`
+	EndOfPreviousEvent int       `json:"Previous Event-End"`
+	BegOfCurrentEvent  int       `json:"Current Event-Start"`
+	EndOfCurrentEvent  int       `json:"Current Event-End"`
+	BegOfNextEvent     int       `json:"Next Event-Start"`
+	PeakCurrentEvent   int       `json:"Peak point"`
+	Comment            string    `json:"Comment"`
+	Power              float64   `json:"Power"`
+}
+
+// Segment is the acquisition made with one pulse width. Multi-pulse traces hold one segment per pulse width,
+// stored one after the other along the fiber, each with its own sample spacing.
+type Segment struct {
+	PulseWidth int64   `json:"Pulse Width(ns)"`
+	Resolution float64 `json:"Resolution(m)"`
+	SampleQTY  int64   `json:"Sample Quantity"`
+	Start      float64 `json:"Start(m)"`
+	End        float64 `json:"End(m)"`
+
+	// DataPoints is the part of Trace.DataPoints acquired with this pulse width.
+	DataPoints [][]float64 `json:"-"`
+}
+
+// DataPtsInfo is the header of the DataPts block.
+type DataPtsInfo struct {
+	Points int            `json:"Points"`
+	Traces []DataPtsTrace `json:"Traces"`
+}
+
+// DataPtsTrace is a trace stored in the DataPts block. A ScaleFactor of 1000 means the samples are in 0.001 dB units.
+type DataPtsTrace struct {
+	Points      int `json:"Points"`
+	ScaleFactor int `json:"Scale Factor"`
+}
+
+// LinkSummary is the trailer of the KeyEvents block, the link figures computed by the instrument.
+// The span is the stretch of fiber the total loss was measured over.
+type LinkSummary struct {
+	TotalLoss float64 `json:"Total Loss(dB)"`
+	SpanStart float64 `json:"Span Start(m)"`
+	SpanEnd   float64 `json:"Span End(m)"`
+	ORL       float64 `json:"ORL(dB)"`
+	ORLStart  float64 `json:"ORL Start(m)"`
+	ORLFinish float64 `json:"ORL Finish(m)"`
+}
+
+// FixInfos struct is the Fixed parameters extracted from the sor file.
+// AR, ARD and FPO (acquisition range and front panel offset) are stored raw like AO and AOD, the thresholds are the
+// ones the instrument analysed the trace with, and TraceType is ST (standard), RT (reverse), DT (difference) or RF (reference).
+type FixInfo struct {
+	DateTime        time.Time
+	Unit            string
+	ActualWL        float64   `json:"Actual Wavelength"`
+	PulseWidthNo    int64     `json:"Pulse Width No"`
+	PulseWidth      []int64   `json:"Pulse Width(ns)"`
+	SampleQTY       []int64   `json:"Sample Quantity"`
+	IOR             float64   `json:"IOR"`
+	RefIndex        float64   `json:"Refraction Index"`
+	FiberSpeed      float64   `json:"Fiber Light Speed"`
+	Resolution      []float64 `json:"Scan Resolution"`
+	Backscattering  float64   `json:"Back-Scattering"`
+	Averaging       int64     `json:"Averaging"`
+	AveragingTime   float64   `json:"Averaging Time"`
+	Range           []float64 `json:"Scan Range"`
+	AO              float64   `json:"AO"`
+	AOD             float64   `json:"AOD"`
+	AR              float64   `json:"Acquisition Range"`
+	ARD             float64   `json:"Acquisition Range Distance"`
+	FPO             float64   `json:"Front Panel Offset"`
+	NoiseFloor      float64   `json:"Noise Floor(dB)"`
+	NoiseFloorScale float64   `json:"Noise Floor Scale Factor"`
+	PowerOffset     float64   `json:"Power Offset First Point(dB)"`
+	LossThreshold   float64   `json:"Loss Threshold(dB)"`
+	ReflThreshold   float64   `json:"Reflectance Threshold(dB)"`
+	EOFThreshold    float64   `json:"End Of Fiber Threshold(dB)"`
+	TraceType       string    `json:"Trace Type"`
+	Window          [4]int64  `json:"Window Coordinates"`
+}
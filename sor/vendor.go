@@ -0,0 +1,62 @@
+package sor
+
+import (
+	"fmt"
+	"sync"
+)
+
+// VendorDecoder decodes the body of a proprietary block, without its name header.
+// The trace holds the standard blocks already decoded, so a decoder can use the fixed parameters to convert positions.
+type VendorDecoder func(d *Trace, body []byte) (any, error)
+
+var (
+	vendorMu       sync.RWMutex
+	vendorDecoders = map[string]VendorDecoder{}
+)
+
+// RegisterVendorDecoder makes Parse decode the named proprietary block with decode.
+// The decoded value is stored in Trace.Vendor under the block name, a decoder registered twice for a block replaces the first one.
+func RegisterVendorDecoder(block string, decode VendorDecoder) {
+	vendorMu.Lock()
+	defer vendorMu.Unlock()
+
+	vendorDecoders[block] = decode
+}
+
+func vendorDecoder(block string) (VendorDecoder, bool) {
+	vendorMu.RLock()
+	defer vendorMu.RUnlock()
+
+	decode, ok := vendorDecoders[block]
+	return decode, ok
+}
+
+// getVendorBlocks runs the registered decoders on the proprietary blocks found in the file.
+// The vendor layouts are not published, so a block which does not decode is recorded as a warning instead of failing the parse.
+func (d *Trace) getVendorBlocks() error {
+	for _, b := range d.Blocks {
+		decode, ok := vendorDecoder(b.Name)
+		if !ok {
+			continue
+		}
+
+		body, err := d.blockBody(b.Name)
+		if err != nil {
+			d.Warnings = append(d.Warnings, err)
+			continue
+		}
+
+		v, err := decode(d, body)
+		if err != nil {
+			d.Warnings = append(d.Warnings, &BlockError{Block: b.Name, Err: fmt.Errorf("%w: %v", ErrMalformedBlock, err)})
+			continue
+		}
+
+		if d.Vendor == nil {
+			d.Vendor = map[string]any{}
+		}
+		d.Vendor[b.Name] = v
+	}
+
+	return nil
+}
@@ -0,0 +1,385 @@
+package sor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultRevision is the Bellcore revision written for traces which were not read from a file.
+const defaultRevision = 200
+
+// writer is the counterpart of reader, it appends little-endian values to a block body.
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) u16(v uint16) {
+	w.Write(binary.LittleEndian.AppendUint16(nil, v))
+}
+
+func (w *writer) i16(v int16) {
+	w.u16(uint16(v))
+}
+
+func (w *writer) u32(v uint32) {
+	w.Write(binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func (w *writer) i32(v int32) {
+	w.u32(uint32(v))
+}
+
+// str writes a fixed length string, padded with spaces or cut to n bytes.
+func (w *writer) str(s string, n int) {
+	if len(s) < n {
+		s += strings.Repeat(" ", n-len(s))
+	}
+	w.WriteString(s[:n])
+}
+
+// cstr writes a NUL terminated string.
+func (w *writer) cstr(s string) {
+	w.WriteString(s)
+	w.WriteByte(0)
+}
+
+// WriteFile encodes the trace as a version 2 sor file and stores it in filename.
+func WriteFile(filename string, d *Trace) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := Write(f, d); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Write encodes the trace as a version 2 sor file.
+// The GenParams, SupParams, FxdParams, DataPts and KeyEvents blocks are generated from the trace fields,
+// every other block read from the original file is copied unchanged, and the Map and Cksum blocks are recalculated.
+func Write(w io.Writer, d *Trace) error {
+	return d.write(w, d.encoders())
+}
+
+// Rewrite encodes the trace like Write, but only the named blocks are generated from the trace fields,
+// all the others are copied byte for byte from the file the trace was read from.
+// It is meant for editing files, so the trace must come from a version 2 file.
+func Rewrite(w io.Writer, d *Trace, blocks ...string) error {
+	if d.version() < 2 {
+		return fmt.Errorf("sor: can not rewrite a version %.2f file, use Write to convert it", d.BellCoreVersion)
+	}
+
+	all := d.encoders()
+	generated := map[string]func() []byte{}
+	for _, name := range blocks {
+		encode, ok := all[name]
+		if !ok {
+			return fmt.Errorf("sor: no encoder for the %s block", name)
+		}
+		generated[name] = encode
+	}
+
+	return d.write(w, generated)
+}
+
+func (d *Trace) encoders() map[string]func() []byte {
+	return map[string]func() []byte{
+		"GenParams": d.encodeGenParams,
+		"SupParams": d.encodeSupParams,
+		"FxdParams": d.encodeFixedParams,
+		"DataPts":   d.encodeDataPoints,
+		"KeyEvents": d.encodeKeyEvents,
+	}
+}
+
+// write lays out the blocks, generating the ones with an encoder and copying the others, then recalculates the Map and Cksum.
+func (d *Trace) write(w io.Writer, generated map[string]func() []byte) error {
+	type block struct {
+		name     string
+		revision int
+		body     []byte
+	}
+
+	source := d.Blocks
+	if len(source) == 0 {
+		source = []Block{{Name: "Map"}, {Name: "GenParams"}, {Name: "SupParams"}, {Name: "FxdParams"}, {Name: "DataPts"}, {Name: "KeyEvents"}, {Name: "Cksum"}}
+	}
+
+	revision := int(math.Round(d.BellCoreVersion * 100))
+	if revision < defaultRevision {
+		revision = defaultRevision
+	}
+
+	var blocks []block
+	for _, b := range source {
+		if b.Name == "Map" || b.Name == "Cksum" {
+			continue
+		}
+
+		rev := b.Revision
+		if rev < defaultRevision {
+			rev = revision
+		}
+
+		if encode, ok := generated[b.Name]; ok {
+			blocks = append(blocks, block{b.Name, rev, encode()})
+			continue
+		}
+
+		if b.Data == nil {
+			return truncated(b.Name)
+		}
+
+		// version 1 blocks have no name header.
+		body := bytes.TrimPrefix(b.Data, append([]byte(b.Name), 0))
+		if len(body) == len(b.Data) && d.version() >= 2 {
+			return &BlockError{Block: b.Name, Err: ErrMalformedBlock}
+		}
+		blocks = append(blocks, block{b.Name, rev, body})
+	}
+	blocks = append(blocks, block{"Cksum", revision, nil})
+
+	m := writer{}
+	m.cstr("Map")
+	m.u16(uint16(revision))
+	size := 4 + 2 + 4 + 2
+	for _, b := range blocks {
+		size += len(b.name) + 1 + 2 + 4
+	}
+	m.u32(uint32(size))
+	m.u16(uint16(len(blocks) + 1))
+
+	for _, b := range blocks {
+		m.cstr(b.name)
+		m.u16(uint16(b.revision))
+		if b.name == "Cksum" {
+			m.u32(uint32(len(b.name) + 1 + 2))
+		} else {
+			m.u32(uint32(len(b.name) + 1 + len(b.body)))
+		}
+	}
+
+	out := m.Bytes()
+	for _, b := range blocks {
+		out = append(out, b.name...)
+		out = append(out, 0)
+		out = append(out, b.body...)
+	}
+	out = binary.LittleEndian.AppendUint16(out, crc16(out))
+
+	_, err := w.Write(out)
+	return err
+}
+
+// unchanged returns the string as it was stored in the source file when only its padding differs from value,
+// so that the fields which were not edited are written back byte for byte.
+func unchanged(value, stored string) string {
+	if strings.TrimSpace(stored) == value {
+		return stored
+	}
+	return value
+}
+
+func (d *Trace) encodeGenParams() []byte {
+	g, p := d.GenParams, d.padded.GenParams
+	g.CableID = unchanged(g.CableID, p.CableID)
+	g.FiberID = unchanged(g.FiberID, p.FiberID)
+	g.LocationA = unchanged(g.LocationA, p.LocationA)
+	g.LocationB = unchanged(g.LocationB, p.LocationB)
+	g.CableCode = unchanged(g.CableCode, p.CableCode)
+	g.Operator = unchanged(g.Operator, p.Operator)
+	g.Comment = unchanged(g.Comment, p.Comment)
+
+	w := writer{}
+
+	fiberType, _ := strconv.Atoi(strings.TrimPrefix(g.FiberType, "G."))
+	wavelength, _ := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(g.OTDRWavelength, "nm")))
+
+	w.str(g.Lang, 2)
+	w.cstr(g.CableID)
+	w.cstr(g.FiberID)
+	w.u16(uint16(fiberType))
+	w.u16(uint16(wavelength))
+	w.cstr(g.LocationA)
+	w.cstr(g.LocationB)
+	w.cstr(g.CableCode)
+	w.str(g.BuildCondition, 2)
+	w.i32(g.UserOffset)
+	w.i32(g.UserOffsetDistance)
+	w.cstr(g.Operator)
+	w.cstr(g.Comment)
+
+	return w.Bytes()
+}
+
+func (d *Trace) encodeSupParams() []byte {
+	s, p := d.Supplier, d.padded.Supplier
+	w := writer{}
+
+	for _, v := range [][2]string{
+		{s.OTDRSupplier, p.OTDRSupplier}, {s.OTDRName, p.OTDRName}, {s.OTDRsn, p.OTDRsn}, {s.OTDRModuleName, p.OTDRModuleName},
+		{s.OTDRModuleSN, p.OTDRModuleSN}, {s.OTDRswVersion, p.OTDRswVersion}, {s.OTDROtherInfo, p.OTDROtherInfo},
+	} {
+		w.cstr(unchanged(v[0], v[1]))
+	}
+
+	return w.Bytes()
+}
+
+func (d *Trace) encodeFixedParams() []byte {
+	f := d.FixedParams
+	w := writer{}
+
+	w.u32(uint32(f.DateTime.Unix()))
+	w.str(f.Unit, 2)
+	w.u16(uint16(math.Round(f.ActualWL * 10)))
+	w.i32(int32(f.AO))
+	w.i32(int32(f.AOD))
+	w.u16(uint16(len(f.PulseWidth)))
+
+	for _, pw := range f.PulseWidth {
+		w.u16(uint16(pw))
+	}
+	for i := range f.PulseWidth {
+		var spacing float64
+		if i < len(f.Resolution) && f.FiberSpeed != 0 {
+			spacing = f.Resolution[i] / f.FiberSpeed * math.Pow(10, 8)
+		}
+		w.u32(uint32(math.Round(spacing)))
+	}
+	for i := range f.PulseWidth {
+		var qty int64
+		if i < len(f.SampleQTY) {
+			qty = f.SampleQTY[i]
+		}
+		w.u32(uint32(qty))
+	}
+
+	w.u32(uint32(f.IOR))
+	w.u16(uint16(math.Round(f.Backscattering * -10)))
+	w.u32(uint32(f.Averaging))
+	w.u16(uint16(math.Round(f.AveragingTime * 600)))
+
+	traceType := f.TraceType
+	if traceType == "" {
+		traceType = "ST"
+	}
+
+	w.i32(int32(f.AR))
+	w.i32(int32(f.ARD))
+	w.i32(int32(f.FPO))
+	w.u16(uint16(math.Round(f.NoiseFloor * -1000)))
+	w.i16(int16(math.Round(f.NoiseFloorScale * 1000)))
+	w.u16(uint16(math.Round(f.PowerOffset * 1000)))
+	w.u16(uint16(math.Round(f.LossThreshold * 1000)))
+	w.u16(uint16(math.Round(f.ReflThreshold * -1000)))
+	w.u16(uint16(math.Round(f.EOFThreshold * 1000)))
+	w.str(traceType, 2)
+	for _, v := range f.Window {
+		w.i32(int32(v))
+	}
+
+	return w.Bytes()
+}
+
+func (d *Trace) encodeDataPoints() []byte {
+	w := writer{}
+
+	// keep the traces and scale factors of the source file, a trace model which does not match the points is written as one trace.
+	traces := d.DataPtsInfo.Traces
+	sum := 0
+	for _, t := range traces {
+		sum += t.Points
+	}
+	if sum != len(d.DataPoints) {
+		traces = []DataPtsTrace{{Points: len(d.DataPoints)}}
+		if len(d.DataPtsInfo.Traces) > 0 {
+			traces[0].ScaleFactor = d.DataPtsInfo.Traces[0].ScaleFactor
+		}
+	}
+
+	// a scale factor is made coarser when the lowest level of its trace does not fit in 16 bits.
+	scales := make([]int, len(traces))
+	first := 0
+	for i, t := range traces {
+		scales[i] = t.ScaleFactor
+		if scales[i] <= 0 {
+			scales[i] = 1000
+		}
+		for _, p := range d.DataPoints[first : first+t.Points] {
+			if need := int(math.Ceil(-p[1] * 1e6 / math.MaxUint16)); need > scales[i] {
+				scales[i] = need
+			}
+		}
+		first += t.Points
+	}
+
+	w.u32(uint32(len(d.DataPoints)))
+	w.u16(uint16(len(traces)))
+	for i, t := range traces {
+		w.u32(uint32(t.Points))
+		w.u16(uint16(scales[i]))
+	}
+
+	first = 0
+	for i, t := range traces {
+		for _, p := range d.DataPoints[first : first+t.Points] {
+			w.u16(uint16(math.Round(p[1] * -1e6 / float64(scales[i]))))
+		}
+		first += t.Points
+	}
+
+	return w.Bytes()
+}
+
+func (d *Trace) encodeKeyEvents() []byte {
+	w := writer{}
+
+	w.u16(uint16(len(d.Events)))
+
+	for _, e := range d.Events {
+		w.u16(uint16(e.EventNumber))
+		w.u32(uint32(d.eventTime(e.EventLocM)))
+		w.i16(int16(math.Round(e.Slope * 1000)))
+		w.i16(int16(math.Round(e.SpliceLoss * 1000)))
+		w.i32(int32(math.Round(e.RefLoss * 1000)))
+		w.str(e.EventType, 8)
+		w.u32(uint32(e.EndOfPreviousEvent))
+		w.u32(uint32(e.BegOfCurrentEvent))
+		w.u32(uint32(e.EndOfCurrentEvent))
+		w.u32(uint32(e.BegOfNextEvent))
+		w.u32(uint32(e.PeakCurrentEvent))
+		w.cstr(strings.TrimRight(e.Comment, "\x00"))
+	}
+
+	s := d.Summary
+	w.i32(int32(math.Round(s.TotalLoss * 1000)))
+	w.i32(int32(d.eventTime(s.SpanStart)))
+	w.u32(uint32(d.eventTime(s.SpanEnd)))
+	w.u16(uint16(math.Round(s.ORL * 1000)))
+	w.i32(int32(d.eventTime(s.ORLStart)))
+	w.u32(uint32(d.eventTime(s.ORLFinish)))
+
+	return w.Bytes()
+}
+
+// eventTime converts a distance in metres back to the 100 ps time units of the KeyEvents block.
+func (d *Trace) eventTime(m float64) float64 {
+	if d.FixedParams.FiberSpeed == 0 {
+		return 0
+	}
+	if d.Offsets.Corrected {
+		m += d.Offsets.User
+	}
+	return math.Round(m / (math.Pow(10, -4) * d.FixedParams.FiberSpeed))
+}
@@ -0,0 +1,117 @@
+package sor
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// generatedBlocks are the blocks Write encodes from the trace fields instead of copying them.
+var generatedBlocks = map[string]bool{"Map": true, "GenParams": true, "SupParams": true, "FxdParams": true, "DataPts": true, "KeyEvents": true, "Cksum": true}
+
+// roundTrip writes the trace and parses the result.
+func roundTrip(t *testing.T, d *Trace) *Trace {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, d); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Checksum.Status != ChecksumValid {
+		t.Errorf("checksum of the written file %s, want %s", out.Checksum.Status, ChecksumValid)
+	}
+	return out
+}
+
+// decoded returns a copy of the trace without the fields which depend on the file layout rather than its content.
+func decoded(d *Trace) Trace {
+	c := *d
+	c.Filename, c.Blocks, c.Checksum, c.Warnings, c.raw = "", nil, Checksum{}, nil, nil
+	return c
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	for _, name := range []string{"../sorfiles/2.sor", "../sorfiles/3.sor", "../sorfiles/exfo.sor"} {
+		in, err := ParseFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := roundTrip(t, in)
+
+		if a, b := decoded(in), decoded(out); !reflect.DeepEqual(a, b) {
+			t.Errorf("%s: the decoded fields differ after a round trip", name)
+			for _, f := range []struct {
+				name string
+				a, b any
+			}{
+				{"GenParams", a.GenParams, b.GenParams},
+				{"Supplier", a.Supplier, b.Supplier},
+				{"FixedParams", a.FixedParams, b.FixedParams},
+				{"DataPtsInfo", a.DataPtsInfo, b.DataPtsInfo},
+				{"DataPoints", a.DataPoints, b.DataPoints},
+				{"Events", a.Events, b.Events},
+				{"Summary", a.Summary, b.Summary},
+				{"TotalLoss", a.TotalLoss, b.TotalLoss},
+				{"Vendor", a.Vendor, b.Vendor},
+			} {
+				if !reflect.DeepEqual(f.a, f.b) {
+					t.Logf("%s:\n%+v\n%+v", f.name, f.a, f.b)
+				}
+			}
+		}
+
+		for _, b := range in.Blocks {
+			if generatedBlocks[b.Name] {
+				continue
+			}
+			written, ok := out.Block(b.Name)
+			if !ok {
+				t.Errorf("%s: block %s dropped", name, b.Name)
+			} else if !bytes.Equal(written.Data, b.Data) {
+				t.Errorf("%s: block %s changed", name, b.Name)
+			}
+		}
+	}
+}
+
+func TestWriteDataPtsTraces(t *testing.T) {
+	in, err := ParseFile("../sorfiles/2.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// split the samples into two traces with their own scale factor, the first one in 0.001 dB down to -60 dB.
+	half := len(in.DataPoints) / 2
+	in.DataPtsInfo.Traces = []DataPtsTrace{{Points: half, ScaleFactor: 1000}, {Points: len(in.DataPoints) - half, ScaleFactor: 1470}}
+	for _, p := range in.DataPoints[:half] {
+		p[1] = math.Max(p[1], -60)
+	}
+
+	out := roundTrip(t, in)
+	if !reflect.DeepEqual(out.DataPtsInfo, in.DataPtsInfo) {
+		t.Errorf("DataPtsInfo %+v, want %+v", out.DataPtsInfo, in.DataPtsInfo)
+	}
+	if !reflect.DeepEqual(out.DataPoints, in.DataPoints) {
+		t.Error("the data points differ after a round trip")
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	in, err := ParseFile("../sorfiles/2.sor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an empty link summary, the total loss then comes from the WaveMTSParams block.
+	in.Summary.TotalLoss = 0
+	in.TotalLoss = 9.999
+
+	if out := roundTrip(t, in); out.Summary.TotalLoss != 0 {
+		t.Errorf("summary total loss %v, want 0", out.Summary.TotalLoss)
+	}
+}
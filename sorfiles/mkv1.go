@@ -0,0 +1,161 @@
+//go:build ignore
+
+// mkv1 writes v1.sor, the standard blocks of 2.sor rewritten in the Bellcore version 1 layout of SR-4731 issue 1.
+// No trace saved by a version 1 instrument is available, so the fixture is made from the version 2 blocks by
+// dropping the fields version 2 added. The blocks are handled as bytes, independently of the sor package.
+//
+//	go run sorfiles/mkv1.go
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"os"
+)
+
+const (
+	source = "sorfiles/2.sor"
+	target = "sorfiles/v1.sor"
+)
+
+func main() {
+	raw, err := os.ReadFile(source)
+	if err != nil {
+		log.Fatal(err)
+	}
+	blocks := readBlocks(raw)
+
+	out := []struct {
+		name string
+		body []byte
+	}{
+		{"GenParams", genParams(blocks["GenParams"])},
+		{"SupParams", blocks["SupParams"]},
+		{"FxdParams", fxdParams(blocks["FxdParams"])},
+		{"DataPts", blocks["DataPts"]},
+		{"KeyEvents", keyEvents(blocks["KeyEvents"])},
+		{"Cksum", []byte{0, 0}},
+	}
+
+	// version 1 has no Map name header, the Map starts with its revision.
+	var entries bytes.Buffer
+	for _, b := range out {
+		entries.WriteString(b.name + "\x00")
+		binary.Write(&entries, binary.LittleEndian, uint16(100))
+		binary.Write(&entries, binary.LittleEndian, uint32(len(b.body)))
+	}
+
+	var file bytes.Buffer
+	binary.Write(&file, binary.LittleEndian, uint16(100))
+	binary.Write(&file, binary.LittleEndian, uint32(8+entries.Len()))
+	binary.Write(&file, binary.LittleEndian, uint16(len(out)+1))
+	file.Write(entries.Bytes())
+	for _, b := range out[:len(out)-1] {
+		file.Write(b.body)
+	}
+
+	// the CRC covers every byte before the Cksum block.
+	binary.Write(&file, binary.LittleEndian, crc16(file.Bytes()))
+
+	if err := os.WriteFile(target, file.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readBlocks returns the bodies of the blocks of a version 2 file, without their name header.
+func readBlocks(raw []byte) map[string][]byte {
+	p := len("Map\x00") + 2
+	size := int(binary.LittleEndian.Uint32(raw[p:]))
+	count := int(binary.LittleEndian.Uint16(raw[p+4:]))
+	p += 6
+
+	blocks := map[string][]byte{}
+	offset := size
+	for i := 1; i < count; i++ {
+		end := p + bytes.IndexByte(raw[p:], 0)
+		name := string(raw[p:end])
+		length := int(binary.LittleEndian.Uint32(raw[end+3:]))
+		p = end + 7
+
+		blocks[name] = raw[offset+len(name)+1 : offset+length]
+		offset += length
+	}
+	return blocks
+}
+
+// genParams drops the fiber type and the user offset distance.
+//
+//	v2: language(2) cable\0 fiber\0 fiber type(2) wavelength(2) location A\0 location B\0 cable code\0
+//	    build condition(2) user offset(4) user offset distance(4) operator\0 comment\0
+func genParams(b []byte) []byte {
+	p := 2
+	p += bytes.IndexByte(b[p:], 0) + 1
+	p += bytes.IndexByte(b[p:], 0) + 1
+	fiberType := p
+
+	p += 4
+	for i := 0; i < 3; i++ {
+		p += bytes.IndexByte(b[p:], 0) + 1
+	}
+	p += 2 + 4
+	userOffsetDistance := p
+
+	return join(b[:fiberType], b[fiberType+2:userOffsetDistance], b[userOffsetDistance+4:])
+}
+
+// fxdParams drops the acquisition offset distance, the averaging time, the acquisition range distance, the trace type
+// and the measurement window.
+//
+//	v2: date(4) units(2) wavelength(2) acquisition offset(4) acquisition offset distance(4) pulse widths(2)
+//	    pulse width(2n) data spacing(4n) points(4n) group index(4) backscatter(2) averages(4) averaging time(2)
+//	    acquisition range(4) acquisition range distance(4) front panel offset(4) noise floor(2) noise floor scale(2)
+//	    power offset(2) loss threshold(2) reflectance threshold(2) end of fiber threshold(2) trace type(2) window(16)
+func fxdParams(b []byte) []byte {
+	n := int(binary.LittleEndian.Uint16(b[16:]))
+	averagingTime := 18 + 10*n + 4 + 2 + 4
+	rangeDistance := averagingTime + 2 + 4
+	traceType := rangeDistance + 4 + 4 + 12
+
+	return join(b[:12], b[16:averagingTime], b[averagingTime+2:rangeDistance], b[rangeDistance+4:traceType])
+}
+
+// keyEvents drops the five marker positions which follow the fixed fields of every event.
+//
+//	v2 event: number(2) time(4) slope(2) splice loss(2) reflectance(4) type(8) markers(20) comment\0
+func keyEvents(b []byte) []byte {
+	n := int(binary.LittleEndian.Uint16(b))
+	parts := [][]byte{b[:2]}
+
+	p := 2
+	for i := 0; i < n; i++ {
+		parts = append(parts, b[p:p+22])
+		p += 42
+		end := p + bytes.IndexByte(b[p:], 0) + 1
+		parts = append(parts, b[p:end])
+		p = end
+	}
+
+	// the link summary is the same in both versions.
+	return join(append(parts, b[p:])...)
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// crc16 is the CRC-16/CCITT (polynomial 0x1021, initial value 0xFFFF) of SR-4731.
+func crc16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}